@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+// admin runs admin command with action create or promote. Users and roles
+// can be managed over rest only by superadmin, so the first one is created
+// here. Password of created superadmin is read from the first line of input.
+func admin(users datalayer.UserStore, args []string, input io.Reader) error {
+	switch {
+	case len(args) == 3 && args[0] == "create":
+		name, email := strings.TrimSpace(args[1]), strings.TrimSpace(args[2])
+		if address, err := mail.ParseAddress(email); err != nil || address.Address != email || name == "" {
+			return fmt.Errorf("Usage: links admin create NAME EMAIL, with valid email")
+		}
+		if _, err := users.FindByEmail(email); err == nil {
+			return fmt.Errorf("User with email %s already exists, use links admin promote %s", email, email)
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+		password, err := bufio.NewReader(input).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			return fmt.Errorf("Password of superadmin must be sent on standard input")
+		}
+		user, err := users.Save(model.User{Name: name, Email: email, Password: password, Superadmin: true})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created superadmin %s with id=%d\n", user.Email, user.ID)
+	case len(args) == 2 && args[0] == "promote":
+		user, err := users.FindByEmail(args[1])
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("User with email %s was not found", args[1])
+		}
+		if err != nil {
+			return err
+		}
+		user.Superadmin = true
+		user.Roles = nil
+		if _, err := users.Save(*user); err != nil {
+			return err
+		}
+		fmt.Printf("promoted %s to superadmin\n", user.Email)
+	default:
+		return fmt.Errorf("Usage: links admin create NAME EMAIL | links admin promote EMAIL")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/chytilp/links/logging"
@@ -18,19 +19,63 @@ var App *Config
 type Config struct {
 	// Database connection string components.
 	Database DbConfig `toml:"database"`
+	// Schema migrations settings.
+	Migrations MigrationsConfig `toml:"migrations"`
 }
 
-// DbConfig is database connection string components struct.
+// MigrationsConfig is schema migrations settings struct.
+type MigrationsConfig struct {
+	// AutoApply enables applying of pending migrations on server startup.
+	AutoApply bool `toml:"auto_apply"`
+}
+
+// Supported database drivers.
+const (
+	MySQL  = "mysql"
+	SQLite = "sqlite"
+)
+
+// DbConfig is database connection string components struct together with
+// settings of connection pool. Driver selects storage backend, Path is used
+// only by sqlite and the network settings only by mysql.
 type DbConfig struct {
-	Address  string
-	Port     int
-	Database string
-	User     string
-	Password string
+	Driver          string
+	Path            string
+	Address         string
+	Port            int
+	Database        string
+	User            string
+	Password        string
+	MaxOpenConns    int      `toml:"max_open_conns"`
+	MaxIdleConns    int      `toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `toml:"conn_max_lifetime"`
+}
+
+// Duration is time.Duration which can be decoded from string like "5m".
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses duration from config file.
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+// GetDriver returns configured database driver, mysql is default.
+func (d *DbConfig) GetDriver() string {
+	if d.Driver == "" {
+		return MySQL
+	}
+	return d.Driver
 }
 
 // GetConnectionString func formats Database string components into connection string.
 func (d *DbConfig) GetConnectionString() string {
+	if d.GetDriver() == SQLite {
+		return fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", d.Path)
+	}
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", d.User, d.Password, d.Address, d.Port, d.Database)
 }
 
@@ -3,39 +3,79 @@ package datalayer
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
-	// import the MySQL Driver
-	_ "github.com/go-sql-driver/mysql"
+	// import the MySQL and SQLite Drivers
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
 
 	"github.com/chytilp/links/config"
 )
 
 var (
-	db *sql.DB
-
 	// ErrNotFound is returned when the no records where matched by the query
 	ErrNotFound = errors.New("not found")
+
+	// ErrForbidden is returned when user is not allowed to change the record
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrInvalidFilter is returned when filter, sort or page parameters of
+	// the query are not valid
+	ErrInvalidFilter = errors.New("invalid filter")
 )
 
+// mysqlDuplicateEntry is MySQL error number of unique key violation.
+const mysqlDuplicateEntry = 1062
+
 // custom type so we can convert sql results to easily
 type scanner func(dest ...interface{}) error
 
-func getDb() *sql.DB {
-	var err error
-	db, err = sql.Open("mysql", config.App.Database.GetConnectionString())
+// Open creates connection pool to database described by cfg. Pool is safe
+// for concurrent use and should be opened once and shared by all repositories.
+func Open(cfg config.DbConfig) (*sql.DB, error) {
+	var driverName string
+	switch cfg.GetDriver() {
+	case config.MySQL:
+		driverName = "mysql"
+	case config.SQLite:
+		driverName = "sqlite3"
+	default:
+		return nil, fmt.Errorf("Unknown database driver %s", cfg.Driver)
+	}
+	db, err := sql.Open(driverName, cfg.GetConnectionString())
 	if err != nil {
-		// if the DB cannot be accessed -> panic
-		//logging.L.Error("Error from sql.open. err: %s", err)
-		panic(err.Error())
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// isDuplicateKey checks if err is violation of unique key reported by MySQL
+// or SQLite.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
 	}
-	return db
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
 }
 
 // NewRecords creates instance of records object.
 func newRecords(db *sql.DB) *records {
-	if db == nil {
-		db = getDb()
-	}
 	records := &records{
 		db: db,
 	}
@@ -47,9 +87,38 @@ type records struct {
 	db *sql.DB
 }
 
+// preparer is common interface of sql.DB and sql.Tx for preparing statements.
+type preparer interface {
+	Prepare(query string) (*sql.Stmt, error)
+}
+
 // insert is generic method for insert record to db.
 func (r *records) insert(values []interface{}, expression string) (int, error) {
-	stmt, err := r.db.Prepare(expression)
+	return insertWith(r.db, values, expression)
+}
+
+// update is generic method for update record to db.
+func (r *records) update(values []interface{}, expression string) error {
+	return updateWith(r.db, values, expression)
+}
+
+// inTransaction runs fn in db transaction, transaction is commited when fn
+// returns no error and rolled back otherwise.
+func (r *records) inTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertWith inserts record to db via db or transaction and returns its id.
+func insertWith(p preparer, values []interface{}, expression string) (int, error) {
+	stmt, err := p.Prepare(expression)
 	if err != nil {
 		return 0, err
 	}
@@ -65,9 +134,9 @@ func (r *records) insert(values []interface{}, expression string) (int, error) {
 	return int(lastID), nil
 }
 
-// update is generic method for update record to db.
-func (r *records) update(values []interface{}, expression string) error {
-	stmt, err := r.db.Prepare(expression)
+// updateWith executes update (or delete) statement via db or transaction.
+func updateWith(p preparer, values []interface{}, expression string) error {
+	stmt, err := p.Prepare(expression)
 	if err != nil {
 		return err
 	}
@@ -79,11 +148,198 @@ func (r *records) update(values []interface{}, expression string) error {
 	return nil
 }
 
-// close db connection.
-func (r *records) close() error {
-	err := r.db.Close()
+// supportsFullText checks if db is MySQL database, which searches by FULLTEXT
+// indexes. Other databases search by LIKE.
+func supportsFullText(db *sql.DB) bool {
+	if db == nil {
+		return false
+	}
+	_, ok := db.Driver().(*mysql.MySQLDriver)
+	return ok
+}
+
+// buildFilters creates sql filter and prepare values from request input.
+// Fields are checked against allowedFields and values converted by convertValue.
+// Filter field can have operator, e.g. l_created[gte]=..., see filterOperators.
+func buildFilters(filters map[string][]string, allowedFields []string,
+	convertValue func(field string, value string) (interface{}, error)) (string, []interface{}, error) {
+	conditions, err := parseFilters(filters, allowedFields, convertValue)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	return nil
+	var filter []string
+	var outValues []interface{}
+	for _, condition := range conditions {
+		sqlCondition, values := condition.sql()
+		filter = append(filter, sqlCondition)
+		outValues = append(outValues, values...)
+	}
+	return strings.Join(filter, " AND "), outValues, nil
+}
+
+// popFilter returns copy of filters without field and values of the removed field.
+func popFilter(filters map[string][]string, field string) (map[string][]string, []string) {
+	values, ok := filters[field]
+	if !ok {
+		return filters, nil
+	}
+	rest := make(map[string][]string, len(filters))
+	for key, value := range filters {
+		if key != field {
+			rest[key] = value
+		}
+	}
+	return rest, values
+}
+
+// sortField type is one field of requested order, e.g. -rating.
+type sortField struct {
+	field      string
+	descending bool
+}
+
+// parseSort parses sort values like rating or -rating, only fields from
+// allowedFields can be used.
+func parseSort(sortValues []string, allowedFields []string) ([]sortField, error) {
+	var result []sortField
+	for _, value := range sortValues {
+		for _, field := range strings.Split(value, ",") {
+			descending := strings.HasPrefix(field, "-")
+			if descending {
+				field = field[1:]
+			}
+			field = strings.ToLower(strings.TrimSpace(field))
+			if field == "" {
+				continue
+			}
+			if !isFieldAllowed(allowedFields, field) {
+				return nil, fmt.Errorf("%w: Sort by field %s is not allowed", ErrInvalidFilter, field)
+			}
+			result = append(result, sortField{field: field, descending: descending})
+		}
+	}
+	return result, nil
+}
+
+// withTiebreaker appends idField to order unless order already contains it,
+// so records with the same sort values always come in the same order.
+func withTiebreaker(order []sortField, idField string) []sortField {
+	for _, field := range order {
+		if field.field == idField {
+			return order
+		}
+	}
+	return append(order, sortField{field: idField})
+}
+
+// buildOrderBy creates sql order by clause from parsed sort fields.
+func buildOrderBy(order []sortField) string {
+	var orderBy []string
+	for _, field := range order {
+		direction := " ASC"
+		if field.descending {
+			direction = " DESC"
+		}
+		orderBy = append(orderBy, field.field+direction)
+	}
+	return strings.Join(orderBy, ", ")
+}
+
+// Page type is requested part of records. Page is either offset based
+// (limit=20&offset=40) or cursor based (limit=20&after=15), where After is
+// id of the last record of previous page. Zero Limit means no limit.
+type Page struct {
+	Limit  int
+	Offset int
+	After  int
+}
+
+// popPage returns copy of filters without page parameters limit, offset and
+// after, and page parsed from them.
+func popPage(filters map[string][]string) (map[string][]string, Page, error) {
+	var page Page
+	params := []struct {
+		name  string
+		value *int
+	}{{"limit", &page.Limit}, {"offset", &page.Offset}, {"after", &page.After}}
+	for _, param := range params {
+		var values []string
+		filters, values = popFilter(filters, param.name)
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) > 1 {
+			return nil, page, fmt.Errorf("%w: Parameter %s can be used only once", ErrInvalidFilter, param.name)
+		}
+		value, err := strconv.Atoi(values[0])
+		if err != nil || value < 0 {
+			return nil, page, fmt.Errorf("%w: Parameter %s must be non negative number, value: %s",
+				ErrInvalidFilter, param.name, values[0])
+		}
+		*param.value = value
+	}
+	if page.Offset > 0 && page.After > 0 {
+		return nil, page, fmt.Errorf("%w: Parameters offset and after can not be used together", ErrInvalidFilter)
+	}
+	return filters, page, nil
+}
+
+// ParsePage reads page parameters limit, offset and after from filters.
+func ParsePage(filters map[string][]string) (Page, error) {
+	_, page, err := popPage(filters)
+	return page, err
+}
+
+// cursorDescending checks that records ordered by order can be paged by id
+// cursor and returns true when ids go in descending order.
+func cursorDescending(order []sortField, idField string) (bool, error) {
+	if len(order) == 0 {
+		return false, nil
+	}
+	if len(order) > 1 || order[0].field != idField {
+		return false, fmt.Errorf("%w: Parameter after can be used only with sort by %s", ErrInvalidFilter, idField)
+	}
+	return order[0].descending, nil
+}
+
+// buildLimit creates sql limit clause for page, empty for page without limit and offset.
+func buildLimit(page Page) (string, []interface{}) {
+	if page.Limit == 0 && page.Offset == 0 {
+		return "", nil
+	}
+	limit := page.Limit
+	if limit == 0 {
+		// sql has no offset without limit
+		limit = math.MaxInt32
+	}
+	return " LIMIT ? OFFSET ?", []interface{}{limit, page.Offset}
+}
+
+// andConditions joins non empty sql conditions by AND.
+func andConditions(conditions ...string) string {
+	var nonEmpty []string
+	for _, condition := range conditions {
+		if strings.TrimSpace(condition) != "" {
+			nonEmpty = append(nonEmpty, condition)
+		}
+	}
+	return strings.Join(nonEmpty, " AND ")
+}
+
+// correctFieldName repairs field name, replace table alias prefix (l_, c_) by alias.
+func correctFieldName(field string) string {
+	if len(field) > 2 && field[1] == '_' {
+		return field[:1] + "." + field[2:]
+	}
+	return field
+}
+
+// isFieldAllowed checks if allowed field comes from request.
+func isFieldAllowed(allowedFields []string, field string) bool {
+	for _, allowed := range allowedFields {
+		if allowed == field {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,85 @@
+package datalayer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrDuplicateLink is returned when link with the same canonical url already exists.
+var ErrDuplicateLink = errors.New("link with the same url already exists")
+
+// DuplicateLinkError is returned by Save when link with the same canonical url
+// already exists, ExistingID is id of that link.
+type DuplicateLinkError struct {
+	ExistingID int
+}
+
+func (e *DuplicateLinkError) Error() string {
+	return fmt.Sprintf("Link with the same url already exists, id=%d", e.ExistingID)
+}
+
+// Unwrap makes errors.Is(err, ErrDuplicateLink) true for DuplicateLinkError.
+func (e *DuplicateLinkError) Unwrap() error {
+	return ErrDuplicateLink
+}
+
+// trackingParams are query parameters added by analytics and ad tools, they
+// do not change the page and are removed from canonical url.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"mc_cid": true, "mc_eid": true, "igshid": true, "_ga": true,
+}
+
+// CanonicalURL returns canonical form of url used to find duplicate links:
+// scheme and host are lower cased, default port, utm_* and other tracking
+// parameters are removed, remaining parameters are sorted, trailing slash of
+// path is removed and so is fragment, unless it is a client side route
+// (#/... or #!...). Text which is not absolute url is only trimmed.
+func CanonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return raw
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	port := parsed.Port()
+	if (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		// IPv6 address
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	parsed.Host = host
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = strings.TrimRight(parsed.RawPath, "/")
+	query := parsed.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(name)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	parsed.ForceQuery = false
+	if !strings.HasPrefix(parsed.Fragment, "/") && !strings.HasPrefix(parsed.Fragment, "!") {
+		parsed.Fragment = ""
+		parsed.RawFragment = ""
+	}
+	return parsed.String()
+}
+
+// canonicalHash returns hash of canonical url stored in indexed column of link
+// table, urls can be longer than MySQL allows for index key.
+func canonicalHash(raw string) string {
+	sum := sha256.Sum256([]byte(CanonicalURL(raw)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,24 @@
+package datalayer
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"HTTPS://Example.COM:443/Path/", "https://example.com/Path"},
+		{"http://example.com:80", "http://example.com"},
+		{"http://example.com:8080/", "http://example.com:8080"},
+		{"https://example.com/?b=2&utm_source=x&a=1&fbclid=y", "https://example.com?a=1&b=2"},
+		{"https://example.com/page#section", "https://example.com/page"},
+		{"https://example.com/#/users/1", "https://example.com#/users/1"},
+		{"https://[::1]:443/", "https://[::1]"},
+		{" not a url ", "not a url"},
+	}
+	for _, c := range cases {
+		if canonical := CanonicalURL(c.input); canonical != c.expected {
+			t.Errorf("CanonicalURL[%s] should return %s, but returns: %s", c.input, c.expected, canonical)
+		}
+	}
+}
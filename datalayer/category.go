@@ -1 +1,405 @@
 package datalayer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+var (
+	// ErrCategoryCycle is returned when category would become its own ancestor.
+	ErrCategoryCycle = errors.New("category cannot be its own ancestor")
+
+	// ErrParentNotFound is returned when parent category of saved category does not exist.
+	ErrParentNotFound = errors.New("parent category not found")
+)
+
+// Categories type wrapps database methods above category table.
+type Categories struct {
+	records         *records
+	fieldsForSelect []string
+	selectPattern   string
+	insertPattern   string
+	updatePattern   string
+	deletePattern   string
+	linksPattern    string
+	subtreePattern  string
+	countPattern    string
+}
+
+// CreateCategories creates and returns instance of Categories struct.
+func CreateCategories(db *sql.DB) *Categories {
+	categories := &Categories{
+		records:         newRecords(db),
+		fieldsForSelect: []string{"c_id", "c_name", "parent_id", "c_active", "c_created"},
+		selectPattern: "SELECT c.id AS c_id, c.name AS c_name, c.parent_id, c.active AS c_active, " +
+			"c.created AS c_created " +
+			"FROM category c ",
+		insertPattern: "INSERT INTO category(name, parent_id) " +
+			"VALUES(?, ?)",
+		updatePattern:  "UPDATE category SET name=?, parent_id=? WHERE id=?",
+		deletePattern:  "UPDATE category SET active=? WHERE id=?",
+		linksPattern:   "UPDATE link SET active=? WHERE category_id IN (%s) AND active IS NULL",
+		subtreePattern: "UPDATE category SET active=? WHERE id IN (%s) AND active IS NULL",
+		countPattern:   "SELECT COUNT(*) FROM link WHERE category_id IN (%s) AND active IS NULL",
+	}
+	return categories
+}
+
+// Get method returns category record from category table by id.
+func (c *Categories) Get(id int) (*model.Category, error) {
+	row := c.records.db.QueryRow(c.selectPattern+" WHERE c.id = ?", id)
+	category, err := c.scanRow(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// Save method insert/update record in category table.
+func (c *Categories) Save(category model.Category) (*model.Category, error) {
+	var id int
+	var err error
+	if err = c.checkParent(category); err != nil {
+		return nil, err
+	}
+	if category.ID > 0 {
+		err := c.update(category)
+		if err != nil {
+			return nil, err
+		}
+		id = category.ID
+	} else {
+		id, err = c.insert(category)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Get(id)
+}
+
+// checkParent verifies that parent of category exists and that category
+// is not placed under itself or under one of its descendants.
+func (c *Categories) checkParent(category model.Category) error {
+	return checkParent(c, category)
+}
+
+// checkParent verifies parent of category in any category store.
+func checkParent(store CategoryStore, category model.Category) error {
+	parentID := category.ParentID
+	visited := make(map[int]bool)
+	for parentID != 0 {
+		if parentID == category.ID || visited[parentID] {
+			return ErrCategoryCycle
+		}
+		visited[parentID] = true
+		parent, err := store.Get(parentID)
+		if err == sql.ErrNoRows {
+			return ErrParentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if category.ID == 0 {
+			// new category has no descendants, existing parent is enough
+			return nil
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+// insert new record to category table.
+func (c *Categories) insert(category model.Category) (int, error) {
+	values := []interface{}{
+		category.Name,
+		category.ParentID,
+	}
+	id, err := c.records.insert(values, c.insertPattern)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// update record in category table.
+func (c *Categories) update(category model.Category) error {
+	values := []interface{}{
+		category.Name,
+		category.ParentID,
+		category.ID,
+	}
+	err := c.records.update(values, c.updatePattern)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete method archives record in category table by id.
+func (c *Categories) Delete(id int, time time.Time) (*model.Category, error) {
+	values := []interface{}{
+		time,
+		id,
+	}
+	err := c.records.update(values, c.deletePattern)
+	if err != nil {
+		return nil, err
+	}
+	return c.Get(id)
+}
+
+// DeleteCascade method archives record in category table by id together
+// with all its active subcategories and all active links of the subtree.
+func (c *Categories) DeleteCascade(id int, time time.Time) (*model.Category, error) {
+	ids, err := c.DescendantIDs(id)
+	if err != nil {
+		return nil, err
+	}
+	err = c.records.inTransaction(func(tx *sql.Tx) error {
+		query, values := inIDs(c.linksPattern, ids, time)
+		if err := updateWith(tx, values, query); err != nil {
+			return err
+		}
+		if len(ids) > 1 {
+			query, values = inIDs(c.subtreePattern, ids[1:], time)
+			if err := updateWith(tx, values, query); err != nil {
+				return err
+			}
+		}
+		return updateWith(tx, []interface{}{time, id}, c.deletePattern)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.Get(id)
+}
+
+// ActiveLinksCount method returns number of active links in category by id
+// and in all its subcategories.
+func (c *Categories) ActiveLinksCount(id int) (int, error) {
+	ids, err := c.DescendantIDs(id)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	query, values := inIDs(c.countPattern, ids)
+	row := c.records.db.QueryRow(query, values...)
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// inIDs fills placeholders of ids to pattern and returns query with values
+// followed by ids.
+func inIDs(pattern string, ids []int, values ...interface{}) (string, []interface{}) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	return fmt.Sprintf(pattern, placeholders), values
+}
+
+// Retrieve method selects from category table records by sended filers.
+func (c *Categories) Retrieve(filters map[string][]string) ([]*model.Category, error) {
+	whereClause, values, err := c.buildFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	var rows *sql.Rows
+	if len(whereClause) != 0 {
+		query := c.selectPattern + "WHERE " + whereClause
+		rows, err = c.records.db.Query(query, values...)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rows, err = c.records.db.Query(c.selectPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+	var result []*model.Category
+	for rows.Next() {
+		category, err := c.scanRow(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, category)
+	}
+	return result, rows.Err()
+}
+
+// Subtree method returns category by id with all its subcategories.
+func (c *Categories) Subtree(id int) (*model.CategoryNode, error) {
+	categories, err := c.Retrieve(nil)
+	if err != nil {
+		return nil, err
+	}
+	return subtree(categories, id)
+}
+
+// Path method returns ancestors of category by id, from root category
+// down to the category itself.
+func (c *Categories) Path(id int) ([]*model.Category, error) {
+	categories, err := c.Retrieve(nil)
+	if err != nil {
+		return nil, err
+	}
+	return categoryPath(categories, id)
+}
+
+// Tree method returns all root categories with their subcategories.
+func (c *Categories) Tree() ([]*model.CategoryNode, error) {
+	categories, err := c.Retrieve(nil)
+	if err != nil {
+		return nil, err
+	}
+	return tree(categories)
+}
+
+// DescendantIDs method returns id of category and ids of all its subcategories.
+func (c *Categories) DescendantIDs(id int) ([]int, error) {
+	node, err := c.Subtree(id)
+	if err != nil {
+		return nil, err
+	}
+	return descendantIDs(node), nil
+}
+
+// subtree returns node of category by id from all categories.
+func subtree(categories []*model.Category, id int) (*model.CategoryNode, error) {
+	for _, category := range categories {
+		if category.ID == id {
+			return buildNode(category, childrenIndex(categories), make(map[int]bool))
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// categoryPath returns ancestors of category by id from all categories.
+func categoryPath(categories []*model.Category, id int) ([]*model.Category, error) {
+	byID := make(map[int]*model.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+	var path []*model.Category
+	visited := make(map[int]bool)
+	for current := id; current != 0; {
+		category, ok := byID[current]
+		if !ok {
+			if current == id {
+				return nil, ErrNotFound
+			}
+			return nil, ErrParentNotFound
+		}
+		if visited[current] {
+			return nil, ErrCategoryCycle
+		}
+		visited[current] = true
+		path = append([]*model.Category{category}, path...)
+		current = category.ParentID
+	}
+	return path, nil
+}
+
+// tree returns root categories with their subcategories from all categories.
+func tree(categories []*model.Category) ([]*model.CategoryNode, error) {
+	children := childrenIndex(categories)
+	visited := make(map[int]bool)
+	tree := []*model.CategoryNode{}
+	for _, category := range children[0] {
+		node, err := buildNode(category, children, visited)
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, node)
+	}
+	return tree, nil
+}
+
+// descendantIDs returns ids of categories in subtree.
+func descendantIDs(node *model.CategoryNode) []int {
+	var ids []int
+	var collect func(node *model.CategoryNode)
+	collect = func(node *model.CategoryNode) {
+		ids = append(ids, node.ID)
+		for _, child := range node.Children {
+			collect(child)
+		}
+	}
+	collect(node)
+	return ids
+}
+
+// childrenIndex groups categories by their parent id.
+func childrenIndex(categories []*model.Category) map[int][]*model.Category {
+	children := make(map[int][]*model.Category)
+	for _, category := range categories {
+		children[category.ParentID] = append(children[category.ParentID], category)
+	}
+	return children
+}
+
+// buildNode recursively creates tree node of category and its subcategories.
+func buildNode(category *model.Category, children map[int][]*model.Category,
+	visited map[int]bool) (*model.CategoryNode, error) {
+	if visited[category.ID] {
+		return nil, ErrCategoryCycle
+	}
+	visited[category.ID] = true
+	node := &model.CategoryNode{Category: *category, Children: []*model.CategoryNode{}}
+	for _, child := range children[category.ID] {
+		childNode, err := buildNode(child, children, visited)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, childNode)
+	}
+	return node, nil
+}
+
+// buildFilters creates sql filter and prepare values from request input.
+func (c *Categories) buildFilters(filters map[string][]string) (string, []interface{}, error) {
+	return buildFilters(filters, c.fieldsForSelect, c.convertValue)
+}
+
+// scanRow fills category structure with values from db record.
+func (c *Categories) scanRow(fn scanner) (*model.Category, error) {
+	category := &model.Category{}
+	err := fn(&category.ID, &category.Name, &category.ParentID, &category.Active,
+		&category.Created)
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// convertValues converts value from request to correct type.
+func (c *Categories) convertValue(field string, value string) (interface{}, error) {
+	switch field {
+	case "c_id", "parent_id":
+		intVal, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		return intVal, nil
+	case "c_name":
+		return value, nil
+	case "c_active", "c_created":
+		// 2014-11-12T11:45:26.371Z
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("Unknown field %s", field)
+}
@@ -1 +1,362 @@
 package datalayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chytilp/links/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func createCategory(id int, name string, parentID int) *model.Category {
+	created := time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC)
+	expectedCategory := &model.Category{
+		ID:       id,
+		Name:     name,
+		ParentID: parentID,
+		Active:   nil,
+		Created:  &created,
+	}
+	return expectedCategory
+}
+
+func createMockCategoryGetExpectedQuery(mock sqlmock.Sqlmock, category *model.Category, id int) {
+	columns := []string{"c_id", "c_name", "parent_id", "c_active", "c_created"}
+	mock.ExpectQuery("^SELECT (.+) FROM category c WHERE c.id = ?").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			category.ID, category.Name, category.ParentID, category.Active,
+			category.Created))
+}
+
+func createMockCategoryRetrieveExpectedQuery(mock sqlmock.Sqlmock, categories []*model.Category) {
+	columns := []string{"c_id", "c_name", "parent_id", "c_active", "c_created"}
+	rows := sqlmock.NewRows(columns)
+	for _, category := range categories {
+		rows.AddRow(category.ID, category.Name, category.ParentID, category.Active,
+			category.Created)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM category c "+
+		"WHERE c.name IN \\(\\?, \\?\\) AND parent_id = \\?").
+		WithArgs("sport", "hudba", 0).
+		WillReturnRows(rows)
+}
+
+func createMockCategoryAllExpectedQuery(mock sqlmock.Sqlmock, categories []*model.Category) {
+	columns := []string{"c_id", "c_name", "parent_id", "c_active", "c_created"}
+	rows := sqlmock.NewRows(columns)
+	for _, category := range categories {
+		rows.AddRow(category.ID, category.Name, category.ParentID, category.Active,
+			category.Created)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM category c$").
+		WillReturnRows(rows)
+}
+
+func createCategoryHierarchy() []*model.Category {
+	return []*model.Category{
+		createCategory(1, "sport", 0),
+		createCategory(2, "tenis", 1),
+		createCategory(3, "wimbledon", 2),
+		createCategory(4, "hudba", 0),
+	}
+}
+
+func createMockCategoryInsertExpectedQuery(mock sqlmock.Sqlmock, category *model.Category, id int) {
+	mock.ExpectPrepare("^INSERT INTO category\\(name, parent_id\\) VALUES\\(\\?, \\?\\)").
+		ExpectExec().
+		WithArgs(category.Name, category.ParentID).
+		WillReturnResult(sqlmock.NewResult(int64(id), 1))
+}
+
+func createMockCategoryUpdateExpectedQuery(mock sqlmock.Sqlmock, category *model.Category) {
+	mock.ExpectPrepare("^UPDATE category SET name=\\?, parent_id=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(category.Name, category.ParentID, category.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func createMockCategoryDeleteExpectedQuery(mock sqlmock.Sqlmock, category *model.Category) {
+	mock.ExpectPrepare("^UPDATE category SET active=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(category.Active, category.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestCategoryGetShouldReturnRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	expectedCategory := createCategory(id, "sport", 0)
+	createMockCategoryGetExpectedQuery(mock, expectedCategory, id)
+	categories := CreateCategories(db)
+	defer db.Close()
+	category, err := categories.Get(id)
+	if err != nil {
+		t.Errorf("Categories.Get[%d] should return result, but error: %v", id, err)
+	}
+	same := cmp.Equal(expectedCategory, category)
+	if !same {
+		t.Errorf("Category object are different: %#v, %#v", expectedCategory, category)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategorySaveShouldInsertRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	category := createCategory(0, "sport", 0)
+	id := 1
+	createMockCategoryInsertExpectedQuery(mock, category, id)
+	createMockCategoryGetExpectedQuery(mock, category, id)
+	categories := CreateCategories(db)
+	defer db.Close()
+	outputCategory, err := categories.Save(*category)
+	if err != nil {
+		t.Errorf("Categories.Save[%#v] should insert record, but error: %v", category, err)
+	}
+	category.ID = outputCategory.ID
+	same := cmp.Equal(outputCategory, category)
+	if !same {
+		t.Errorf("Category object are different: %#v, %#v", outputCategory, category)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategorySaveShouldUpdateRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 2
+	category := createCategory(id, "tenis", 1)
+	createMockCategoryGetExpectedQuery(mock, createCategory(1, "sport", 0), 1)
+	createMockCategoryUpdateExpectedQuery(mock, category)
+	createMockCategoryGetExpectedQuery(mock, category, id)
+	categories := CreateCategories(db)
+	defer db.Close()
+	outputCategory, err := categories.Save(*category)
+	if err != nil {
+		t.Errorf("Categories.Save[%#v] should update record, but error: %v", category, err)
+	}
+	same := cmp.Equal(outputCategory, category)
+	if !same {
+		t.Errorf("Category object are different: %#v, %#v", outputCategory, category)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategoryDeleteShouldArchiveRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	category := createCategory(id, "sport", 0)
+	now := time.Now()
+	category.Active = &now
+	createMockCategoryDeleteExpectedQuery(mock, category)
+	createMockCategoryGetExpectedQuery(mock, category, id)
+	categories := CreateCategories(db)
+	defer db.Close()
+	outputCategory, err := categories.Delete(id, now)
+	if err != nil {
+		t.Errorf("Categories.Delete[%d, %s] should archive record, but error: %v",
+			id, now.Format("2006-01-02 15:04:05"), err)
+	}
+	same := cmp.Equal(outputCategory, category)
+	if !same {
+		t.Errorf("Category object are different: %#v, %#v", outputCategory, category)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategoryRetriveShouldReturnRecords(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	categories := make([]*model.Category, 2)
+	categories[0] = createCategory(1, "sport", 0)
+	categories[1] = createCategory(3, "hudba", 0)
+	createMockCategoryRetrieveExpectedQuery(mock, categories)
+	categoriesObj := CreateCategories(db)
+	defer db.Close()
+	filters := make(map[string][]string)
+	filters["c_name"] = []string{"sport", "hudba"}
+	filters["parent_id"] = []string{"0"}
+	outputCategories, err := categoriesObj.Retrieve(filters)
+	if err != nil {
+		t.Errorf("Categories.Retrieve[%v] should retrieve records, but error: %v",
+			filters, err)
+	}
+	same := cmp.Equal(outputCategories, categories)
+	if !same {
+		t.Errorf("Category object are different: %#v, %#v", outputCategories, categories)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategoryRetriveShouldRejectUnknownField(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	categoriesObj := CreateCategories(db)
+	defer db.Close()
+	filters := make(map[string][]string)
+	filters["l_name"] = []string{"tenis"}
+	_, err := categoriesObj.Retrieve(filters)
+	if err == nil {
+		t.Errorf("Categories.Retrieve[%v] should fail on not allowed field", filters)
+	}
+}
+
+func TestCategorySaveShouldRejectCycle(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	hierarchy := createCategoryHierarchy()
+	createMockCategoryGetExpectedQuery(mock, hierarchy[2], 3)
+	createMockCategoryGetExpectedQuery(mock, hierarchy[1], 2)
+	categories := CreateCategories(db)
+	defer db.Close()
+	category := createCategory(1, "sport", 3)
+	_, err := categories.Save(*category)
+	if err != ErrCategoryCycle {
+		t.Errorf("Categories.Save[%#v] should reject cycle, but error: %v", category, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategorySaveShouldRejectMissingParent(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	columns := []string{"c_id", "c_name", "parent_id", "c_active", "c_created"}
+	mock.ExpectQuery("^SELECT (.+) FROM category c WHERE c.id = ?").
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows(columns))
+	categories := CreateCategories(db)
+	defer db.Close()
+	category := createCategory(0, "tenis", 9)
+	_, err := categories.Save(*category)
+	if err != ErrParentNotFound {
+		t.Errorf("Categories.Save[%#v] should reject missing parent, but error: %v", category, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategoryTreeShouldNestCategories(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	hierarchy := createCategoryHierarchy()
+	createMockCategoryAllExpectedQuery(mock, hierarchy)
+	categories := CreateCategories(db)
+	defer db.Close()
+	tree, err := categories.Tree()
+	if err != nil {
+		t.Errorf("Categories.Tree should return tree, but error: %v", err)
+	}
+	leaf := &model.CategoryNode{Category: *hierarchy[2], Children: []*model.CategoryNode{}}
+	middle := &model.CategoryNode{Category: *hierarchy[1], Children: []*model.CategoryNode{leaf}}
+	expectedTree := []*model.CategoryNode{
+		{Category: *hierarchy[0], Children: []*model.CategoryNode{middle}},
+		{Category: *hierarchy[3], Children: []*model.CategoryNode{}},
+	}
+	same := cmp.Equal(tree, expectedTree)
+	if !same {
+		t.Errorf("Category trees are different: %#v, %#v", tree, expectedTree)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategoryPathShouldReturnAncestors(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	hierarchy := createCategoryHierarchy()
+	createMockCategoryAllExpectedQuery(mock, hierarchy)
+	categories := CreateCategories(db)
+	defer db.Close()
+	path, err := categories.Path(3)
+	if err != nil {
+		t.Errorf("Categories.Path[%d] should return path, but error: %v", 3, err)
+	}
+	same := cmp.Equal(path, hierarchy[:3])
+	if !same {
+		t.Errorf("Category paths are different: %#v, %#v", path, hierarchy[:3])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategoryDescendantIDsShouldReturnSubtree(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	createMockCategoryAllExpectedQuery(mock, createCategoryHierarchy())
+	categories := CreateCategories(db)
+	defer db.Close()
+	ids, err := categories.DescendantIDs(2)
+	if err != nil {
+		t.Errorf("Categories.DescendantIDs[%d] should return ids, but error: %v", 2, err)
+	}
+	same := cmp.Equal(ids, []int{2, 3})
+	if !same {
+		t.Errorf("Category ids are different: %v, %v", ids, []int{2, 3})
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategoryDeleteCascadeShouldArchiveSubtree(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 2
+	hierarchy := createCategoryHierarchy()
+	category := createCategory(id, "tenis", 1)
+	now := time.Now()
+	category.Active = &now
+	createMockCategoryAllExpectedQuery(mock, hierarchy)
+	mock.ExpectBegin()
+	mock.ExpectPrepare("^UPDATE link SET active=\\? WHERE category_id IN \\(\\?, \\?\\) AND active IS NULL").
+		ExpectExec().
+		WithArgs(now, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectPrepare("^UPDATE category SET active=\\? WHERE id IN \\(\\?\\) AND active IS NULL").
+		ExpectExec().
+		WithArgs(now, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	createMockCategoryDeleteExpectedQuery(mock, category)
+	mock.ExpectCommit()
+	createMockCategoryGetExpectedQuery(mock, category, id)
+	categories := CreateCategories(db)
+	defer db.Close()
+	outputCategory, err := categories.DeleteCascade(id, now)
+	if err != nil {
+		t.Errorf("Categories.DeleteCascade[%d] should archive record, but error: %v", id, err)
+	}
+	same := cmp.Equal(outputCategory, category)
+	if !same {
+		t.Errorf("Category object are different: %#v, %#v", outputCategory, category)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCategoryActiveLinksCountShouldCountLinks(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	createMockCategoryAllExpectedQuery(mock, createCategoryHierarchy())
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM link WHERE category_id IN \\(\\?, \\?, \\?\\) AND active IS NULL").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	categories := CreateCategories(db)
+	defer db.Close()
+	count, err := categories.ActiveLinksCount(1)
+	if err != nil {
+		t.Errorf("Categories.ActiveLinksCount[%d] should count links, but error: %v", 1, err)
+	}
+	if count != 3 {
+		t.Errorf("Categories.ActiveLinksCount[%d] returned %d, expected %d", 1, count, 3)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,184 @@
+package datalayer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// filterOperators are operators which can be used in filter, e.g.
+// l_created[gte]=2021-03-01T00:00:00Z. Filter without operator uses eq.
+var filterOperators = []string{"eq", "ne", "gt", "gte", "lt", "lte", "like", "null"}
+
+// filterCondition type is one condition of filter with converted values.
+type filterCondition struct {
+	field    string
+	operator string
+	values   []interface{}
+}
+
+// parseFilters validates filters from request and converts their values.
+// Fields are checked against allowedFields and values converted by convertValue,
+// conditions are returned in stable order so the same filters give the same query.
+func parseFilters(filters map[string][]string, allowedFields []string,
+	convertValue func(field string, value string) (interface{}, error)) ([]filterCondition, error) {
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var conditions []filterCondition
+	for _, key := range keys {
+		field, operator, err := parseFilterKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if !isFieldAllowed(allowedFields, field) {
+			return nil, fmt.Errorf("%w: Field %s is not allowed", ErrInvalidFilter, field)
+		}
+		condition := filterCondition{field: field, operator: operator}
+		for _, value := range filters[key] {
+			typedValue, err := convertFilterValue(field, operator, value, convertValue)
+			if err != nil {
+				return nil, err
+			}
+			condition.values = append(condition.values, typedValue)
+		}
+		if operator == "null" && len(condition.values) != 1 {
+			return nil, fmt.Errorf("%w: Operator null of field %s needs exactly one value", ErrInvalidFilter, field)
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// parseFilterKey splits filter key like l_created[gte] to field and operator.
+func parseFilterKey(key string) (string, string, error) {
+	key = strings.ToLower(key)
+	open := strings.Index(key, "[")
+	if open < 0 {
+		return key, "eq", nil
+	}
+	field := key[:open]
+	if !strings.HasSuffix(key, "]") {
+		return "", "", fmt.Errorf("%w: Filter %s is not valid, use field[operator]=value", ErrInvalidFilter, key)
+	}
+	operator := key[open+1 : len(key)-1]
+	if !isFieldAllowed(filterOperators, operator) {
+		return "", "", fmt.Errorf("%w: Unknown operator %s of field %s, use one of %s", ErrInvalidFilter,
+			operator, field, strings.Join(filterOperators, ", "))
+	}
+	return field, operator, nil
+}
+
+// convertFilterValue converts value of filter to type used by operator, null
+// takes true or false and like needs text field.
+func convertFilterValue(field string, operator string, value string,
+	convertValue func(field string, value string) (interface{}, error)) (interface{}, error) {
+	if operator == "null" {
+		isNull, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: Operator null of field %s needs true or false, value: %s",
+				ErrInvalidFilter, field, value)
+		}
+		return isNull, nil
+	}
+	typedValue, err := convertValue(field, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFilter, err)
+	}
+	if _, text := typedValue.(string); operator == "like" && !text {
+		return nil, fmt.Errorf("%w: Operator like can be used only with text field, not %s",
+			ErrInvalidFilter, field)
+	}
+	return typedValue, nil
+}
+
+// likePattern creates pattern matching text containing value, special
+// characters of value are escaped by !.
+func likePattern(value string) string {
+	replacer := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return "%" + replacer.Replace(value) + "%"
+}
+
+// sql creates sql condition and its values.
+func (c filterCondition) sql() (string, []interface{}) {
+	column := correctFieldName(c.field)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(c.values)), ", ")
+	switch c.operator {
+	case "eq":
+		if len(c.values) == 1 {
+			return column + " = ?", c.values
+		}
+		return column + " IN (" + placeholders + ")", c.values
+	case "ne":
+		if len(c.values) == 1 {
+			return column + " <> ?", c.values
+		}
+		return column + " NOT IN (" + placeholders + ")", c.values
+	case "null":
+		if c.values[0].(bool) {
+			return column + " IS NULL", nil
+		}
+		return column + " IS NOT NULL", nil
+	case "like":
+		var conditions []string
+		var values []interface{}
+		for _, value := range c.values {
+			conditions = append(conditions, column+" LIKE ? ESCAPE '!'")
+			values = append(values, likePattern(value.(string)))
+		}
+		return strings.Join(conditions, " AND "), values
+	}
+	comparison := map[string]string{"gt": " > ?", "gte": " >= ?", "lt": " < ?", "lte": " <= ?"}[c.operator]
+	conditions := make([]string, len(c.values))
+	for i := range c.values {
+		conditions[i] = column + comparison
+	}
+	return strings.Join(conditions, " AND "), c.values
+}
+
+// match checks if record value meets the condition. Null values match only
+// null operator, like in sql.
+func (c filterCondition) match(recordValue interface{}) bool {
+	if c.operator == "null" {
+		return isNullValue(recordValue) == c.values[0].(bool)
+	}
+	if isNullValue(recordValue) {
+		return false
+	}
+	switch c.operator {
+	case "eq", "ne":
+		for _, value := range c.values {
+			if equalValues(recordValue, value) {
+				return c.operator == "eq"
+			}
+		}
+		return c.operator == "ne"
+	}
+	for _, value := range c.values {
+		if !matchValue(c.operator, recordValue, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchValue checks record value against one value of operator like, gt, gte, lt or lte.
+func matchValue(operator string, recordValue interface{}, value interface{}) bool {
+	if operator == "like" {
+		text, _ := recordValue.(string)
+		return strings.Contains(strings.ToLower(text), strings.ToLower(value.(string)))
+	}
+	compared := compareValues(recordValue, value)
+	switch operator {
+	case "gt":
+		return compared > 0
+	case "gte":
+		return compared >= 0
+	case "lt":
+		return compared < 0
+	}
+	return compared <= 0
+}
@@ -0,0 +1,56 @@
+package datalayer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildFiltersShouldUseOperators(t *testing.T) {
+	links := CreateLinks(nil)
+	created := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	filters := map[string][]string{
+		"l_created[gte]": {"2021-03-01T00:00:00Z"},
+		"l_name[like]":   {"50%_off"},
+		"c_id[ne]":       {"1", "2"},
+		"l_active[null]": {"true"},
+		"l_id":           {"7"},
+	}
+	where, values, err := links.buildFilters(filters)
+	if err != nil {
+		t.Fatalf("Links.buildFilters[%v] should build filter, but error: %v", filters, err)
+	}
+	expectedWhere := "c.id NOT IN (?, ?) AND l.active IS NULL AND l.created >= ? AND " +
+		"l.id = ? AND l.name LIKE ? ESCAPE '!'"
+	if where != expectedWhere {
+		t.Errorf("Links.buildFilters[%v] should return %s, but returns: %s", filters, expectedWhere, where)
+	}
+	expectedValues := []interface{}{1, 2, created, 7, "%50!%!_off%"}
+	if !cmp.Equal(values, expectedValues) {
+		t.Errorf("Links.buildFilters[%v] should return values %v, but returns: %v", filters,
+			expectedValues, values)
+	}
+}
+
+func TestBuildFiltersShouldRejectInvalidOperator(t *testing.T) {
+	links := CreateLinks(nil)
+	for _, test := range []struct {
+		key     string
+		value   string
+		message string
+	}{
+		{"l_name[between]", "a", "Unknown operator between of field l_name"},
+		{"l_name[like", "a", "Filter l_name[like is not valid"},
+		{"l_id[like]", "1", "Operator like can be used only with text field"},
+		{"l_active[null]", "yes", "Operator null of field l_active needs true or false"},
+	} {
+		filters := map[string][]string{test.key: {test.value}}
+		_, _, err := links.buildFilters(filters)
+		if !errors.Is(err, ErrInvalidFilter) || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Links.buildFilters[%v] should fail with %s, but error: %v", filters, test.message, err)
+		}
+	}
+}
@@ -11,41 +11,168 @@ import (
 	"github.com/chytilp/links/model"
 )
 
+const (
+	// activeLinkPattern limits links l in categories c to active links in active categories.
+	activeLinkPattern = "l.active IS NULL AND c.active IS NULL"
+	// visibleLinkPattern limits links l to links shared with user or with
+	// one of the user's roles, it takes user id twice.
+	visibleLinkPattern = "(EXISTS (SELECT 1 FROM user_link ul WHERE ul.link_id = l.id AND ul.user_id = ?) " +
+		"OR EXISTS (SELECT 1 FROM role_link rl JOIN user_role ur on rl.role_id = ur.role_id " +
+		"WHERE rl.link_id = l.id AND ur.user_id = ?))"
+)
+
 // Links type wrapps database methods above link table.
 type Links struct {
-	records         *records
-	fieldsForSelect []string
-	selectPattern   string
-	insertPattern   string
-	updatePattern   string
-	deletePattern   string
+	records            *records
+	tags               *Tags
+	viewer             *model.User
+	includeInactive    bool
+	allowDuplicate     bool
+	fieldsForSelect    []string
+	sortFields         []string
+	selectPattern      string
+	countPattern       string
+	searchPattern      string
+	matchPattern       string
+	likeMatchPattern   string
+	notesPattern       string
+	fullText           bool
+	insertPattern      string
+	updatePattern      string
+	patchPattern       string
+	duplicatePattern   string
+	deletePattern      string
+	restorePattern     string
+	activePattern      string
+	tagAnyPattern      string
+	tagAllPattern      string
+	visibilityPattern  string
+	ownerPattern       string
+	insertOwnerPattern string
 }
 
 // CreateLinks creates and returns instance of Links struct.
 func CreateLinks(db *sql.DB) *Links {
-	if db == nil {
-		db = getDb()
-	}
+	fieldsForSelect := []string{"l_id", "link", "l_name", "l_active", "l_created",
+		"c_id", "c_name", "parent_id", "c_active", "c_created"}
+	columns := "l.id AS l_id, l.link, l.name AS l_name, l.active AS l_active, " +
+		"l.created AS l_created, c.id AS c_id, c.name AS c_name, c.parent_id, c.active AS c_active, " +
+		" c.created AS c_created, COALESCE(s.rating, 0) AS rating, " +
+		"COALESCE(s.rating_count, 0) AS rating_count, " +
+		"(SELECT GROUP_CONCAT(t.name) FROM link_tag lt JOIN tag t on lt.tag_id = t.id " +
+		"WHERE lt.link_id = l.id) AS tags "
+	from := "FROM link l " +
+		"JOIN category c on l.category_id = c.id " +
+		"LEFT JOIN (SELECT link_id, AVG(stars) AS rating, COUNT(*) AS rating_count " +
+		"FROM star GROUP BY link_id) s on s.link_id = l.id "
 	links := &Links{
-		records: newRecords(db),
-		fieldsForSelect: []string{"l_id", "link", "l_name", "l_active", "l_created",
-			"c_id", "c_name", "parent_id", "c_active", "c_created"},
-		selectPattern: "SELECT l.id AS l_id, l.link, l.name AS l_name, l.active AS l_active, " +
-			"l.created AS l_created, c.id AS c_id, c.name AS c_name, c.parent_id, c.active AS c_active, " +
-			" c.created AS c_created " +
-			"FROM link l " +
+		records:         newRecords(db),
+		tags:            CreateTags(db),
+		fieldsForSelect: fieldsForSelect,
+		sortFields:      append(append([]string{}, fieldsForSelect...), "rating", "rating_count"),
+		selectPattern:   "SELECT " + columns + from,
+		searchPattern:   "SELECT " + columns + ", m.score " + from + "JOIN (%s) m on m.link_id = l.id ",
+		matchPattern: "SELECT link_id, SUM(score) AS score FROM (" +
+			"SELECT id AS link_id, 2 * MATCH(name, link) AGAINST (?) AS score FROM link " +
+			"WHERE MATCH(name, link) AGAINST (?) " +
+			"UNION ALL SELECT l.id, MATCH(c.name) AGAINST (?) FROM link l " +
+			"JOIN category c on l.category_id = c.id WHERE MATCH(c.name) AGAINST (?) " +
+			"UNION ALL SELECT link_id, MATCH(note) AGAINST (?) FROM note " +
+			"WHERE private = ? AND MATCH(note) AGAINST (?)" +
+			") matches GROUP BY link_id",
+		likeMatchPattern: "SELECT id AS link_id, 2 AS score FROM link WHERE name LIKE ? ESCAPE '!' " +
+			"UNION ALL SELECT id, 1 FROM link WHERE link LIKE ? ESCAPE '!' " +
+			"UNION ALL SELECT l.id, 1 FROM link l JOIN category c on l.category_id = c.id " +
+			"WHERE c.name LIKE ? ESCAPE '!' " +
+			"UNION ALL SELECT link_id, 1 FROM note WHERE private = ? AND note LIKE ? ESCAPE '!'",
+		notesPattern: "SELECT link_id, note FROM note WHERE private = ? AND link_id IN (%s) ORDER BY id",
+		fullText:     supportsFullText(db),
+		countPattern: "SELECT COUNT(*) FROM link l " +
 			"JOIN category c on l.category_id = c.id ",
-		insertPattern: "INSERT INTO link(link, name, category_id) " +
+		insertPattern: "INSERT INTO link(link, name, category_id, canonical_hash) " +
+			"VALUES(?, ?, ?, ?)",
+		updatePattern:    "UPDATE link SET link=?, name=?, category_id=?, canonical_hash=? WHERE id=?",
+		patchPattern:     "UPDATE link SET %s WHERE id=?",
+		duplicatePattern: "SELECT id FROM link WHERE canonical_hash = ? AND id <> ? ORDER BY id",
+		deletePattern:    "UPDATE link SET active=? WHERE id=?",
+		restorePattern:   "UPDATE link SET active=NULL WHERE id=?",
+		activePattern:    activeLinkPattern,
+		tagAnyPattern: "l.id IN (SELECT lt.link_id FROM link_tag lt JOIN tag t on lt.tag_id = t.id " +
+			"WHERE t.name IN (%s))",
+		tagAllPattern: "l.id IN (SELECT lt.link_id FROM link_tag lt JOIN tag t on lt.tag_id = t.id " +
+			"WHERE t.name IN (%s) GROUP BY lt.link_id HAVING COUNT(*) = ?)",
+		visibilityPattern: visibleLinkPattern,
+		ownerPattern:      "SELECT COUNT(*) FROM user_link WHERE link_id = ? AND user_id = ? AND owner = ?",
+		insertOwnerPattern: "INSERT INTO user_link(user_id, link_id, owner) " +
 			"VALUES(?, ?, ?)",
-		updatePattern: "UPDATE link SET link=?, name=?, category_id=? WHERE id=?",
-		deletePattern: "UPDATE link SET active=? WHERE id=?",
 	}
 	return links
 }
 
+// ForUser method returns Links limited to links visible for user: links
+// the user owns, links shared with the user or with one of the user's roles.
+// Superadmin sees all links. Only owners can change links.
+func (l *Links) ForUser(user *model.User) LinkStore {
+	scoped := *l
+	scoped.viewer = user
+	return &scoped
+}
+
+// IncludeInactive method returns Links which return also archived links and
+// links in archived categories, those are hidden by default.
+func (l *Links) IncludeInactive() LinkStore {
+	return l.withInactive()
+}
+
+// AllowDuplicate method returns Links which save links even when link with
+// the same canonical url already exists. Such links are saved without
+// canonical url hash and do not block later links with the same url.
+func (l *Links) AllowDuplicate() LinkStore {
+	scoped := *l
+	scoped.allowDuplicate = true
+	return &scoped
+}
+
+func (l *Links) withInactive() *Links {
+	scoped := *l
+	scoped.includeInactive = true
+	return &scoped
+}
+
+// visibility returns sql condition limiting links to active links visible for viewer.
+func (l *Links) visibility() (string, []interface{}) {
+	var active string
+	if !l.includeInactive {
+		active = l.activePattern
+	}
+	if l.viewer == nil || l.viewer.Superadmin {
+		return active, nil
+	}
+	return andConditions(active, l.visibilityPattern), []interface{}{l.viewer.ID, l.viewer.ID}
+}
+
+// CanWrite method checks if viewer is allowed to change link by id.
+func (l *Links) CanWrite(id int) (bool, error) {
+	if l.viewer == nil || l.viewer.Superadmin {
+		return true, nil
+	}
+	var count int
+	row := l.records.db.QueryRow(l.ownerPattern, id, l.viewer.ID, true)
+	if err := row.Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 // Get method returns link record from link table by id.
 func (l *Links) Get(id int) (*model.Link, error) {
-	row := l.records.db.QueryRow(l.selectPattern+" WHERE l.id = ?", id)
+	query := l.selectPattern + " WHERE l.id = ?"
+	values := []interface{}{id}
+	if visibility, visibilityValues := l.visibility(); visibility != "" {
+		query += " AND " + visibility
+		values = append(values, visibilityValues...)
+	}
+	row := l.records.db.QueryRow(query, values...)
 	link, err := l.scanRow(row.Scan)
 	if err != nil {
 		return nil, err
@@ -53,33 +180,182 @@ func (l *Links) Get(id int) (*model.Link, error) {
 	return link, nil
 }
 
-// Save method insert/update record in link table.
+// Save method insert/update record in link table. Tags of link are replaced
+// by link.Tags, when link.Tags is nil tags are not changed. Link with the same
+// canonical url as another active link visible for viewer fails with
+// DuplicateLinkError, unless duplicates are allowed.
 func (l *Links) Save(link model.Link) (*model.Link, error) {
 	var id int
 	var err error
+	if link.Tags, err = normalizeTags(link.Tags); err != nil {
+		return nil, err
+	}
+	var hash interface{}
+	if !l.allowDuplicate {
+		hash = canonicalHash(link.Link)
+	}
 	if link.ID > 0 {
-		err := l.update(link)
-		if err != nil {
+		if err := l.checkWrite(link.ID); err != nil {
 			return nil, err
 		}
+	}
+	if err := l.checkDuplicate(hash, link.ID); err != nil {
+		return nil, err
+	}
+	if link.ID > 0 {
+		err = l.update(link, hash)
 		id = link.ID
 	} else {
-		id, err = l.insert(link)
-		if err != nil {
+		id, err = l.insert(link, hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.withInactive().Get(id)
+}
+
+// LinkPatch type is partial change of link, only non nil fields are changed.
+// Empty non nil Tags remove all tags of link.
+type LinkPatch struct {
+	Link       *string
+	Name       *string
+	CategoryID *int
+	Tags       []string
+}
+
+// Patch method changes fields of link by id set in patch, update statement
+// contains only changed columns. Url of another link fails with
+// DuplicateLinkError, unless duplicates are allowed.
+func (l *Links) Patch(id int, patch LinkPatch) (*model.Link, error) {
+	tags, err := normalizeTags(patch.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.checkWrite(id); err != nil {
+		return nil, err
+	}
+	var columns []string
+	var values []interface{}
+	var hash interface{}
+	if patch.Link != nil {
+		if !l.allowDuplicate {
+			hash = canonicalHash(*patch.Link)
+		}
+		if err := l.checkDuplicate(hash, id); err != nil {
 			return nil, err
 		}
+		columns = append(columns, "link=?", "canonical_hash=?")
+		values = append(values, *patch.Link, hash)
+	}
+	if patch.Name != nil {
+		columns = append(columns, "name=?")
+		values = append(values, *patch.Name)
+	}
+	if patch.CategoryID != nil {
+		columns = append(columns, "category_id=?")
+		values = append(values, *patch.CategoryID)
+	}
+	values = append(values, id)
+	query := fmt.Sprintf(l.patchPattern, strings.Join(columns, ", "))
+	switch {
+	case tags != nil:
+		err = l.records.inTransaction(func(tx *sql.Tx) error {
+			if len(columns) > 0 {
+				if err := updateWith(tx, values, query); err != nil {
+					return err
+				}
+			}
+			return l.tags.setLinkTags(tx, id, tags)
+		})
+	case len(columns) > 0:
+		err = l.records.update(values, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.withInactive().Get(id)
+}
+
+// checkDuplicate returns DuplicateLinkError when active link visible for
+// viewer other than link by id has canonical url hash. Nil hash is never
+// duplicate, links which viewer can not see do not block the save.
+func (l *Links) checkDuplicate(hash interface{}, id int) error {
+	if hash == nil {
+		return nil
+	}
+	rows, err := l.records.db.Query(l.duplicatePattern, hash, id)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var existingID int
+		if err := rows.Scan(&existingID); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, existingID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	visible := *l
+	visible.includeInactive = false
+	for _, existingID := range ids {
+		_, err := visible.Get(existingID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return &DuplicateLinkError{ExistingID: existingID}
+	}
+	return nil
+}
+
+// checkWrite returns ErrForbidden when viewer is not allowed to change link by id.
+func (l *Links) checkWrite(id int) error {
+	allowed, err := l.CanWrite(id)
+	if err != nil {
+		return err
 	}
-	return l.Get(id)
+	if !allowed {
+		return ErrForbidden
+	}
+	return nil
 }
 
-// insert new record to link table.
-func (l *Links) insert(link model.Link) (int, error) {
+// insert new record to link table, viewer becomes owner of the link.
+func (l *Links) insert(link model.Link, hash interface{}) (int, error) {
 	values := []interface{}{
 		link.Link,
 		link.Name,
 		link.Category.ID,
+		hash,
 	}
-	id, err := l.records.insert(values, l.insertPattern)
+	if l.viewer == nil && link.Tags == nil {
+		return l.records.insert(values, l.insertPattern)
+	}
+	var id int
+	err := l.records.inTransaction(func(tx *sql.Tx) error {
+		var err error
+		id, err = insertWith(tx, values, l.insertPattern)
+		if err != nil {
+			return err
+		}
+		if l.viewer != nil {
+			_, err = insertWith(tx, []interface{}{l.viewer.ID, id, true}, l.insertOwnerPattern)
+			if err != nil {
+				return err
+			}
+		}
+		if link.Tags != nil {
+			return l.tags.setLinkTags(tx, id, link.Tags)
+		}
+		return nil
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -87,22 +363,30 @@ func (l *Links) insert(link model.Link) (int, error) {
 }
 
 // update record in link table.
-func (l *Links) update(link model.Link) error {
+func (l *Links) update(link model.Link, hash interface{}) error {
 	values := []interface{}{
 		link.Link,
 		link.Name,
 		link.Category.ID,
+		hash,
 		link.ID,
 	}
-	err := l.records.update(values, l.updatePattern)
-	if err != nil {
-		return err
+	if link.Tags == nil {
+		return l.records.update(values, l.updatePattern)
 	}
-	return nil
+	return l.records.inTransaction(func(tx *sql.Tx) error {
+		if err := updateWith(tx, values, l.updatePattern); err != nil {
+			return err
+		}
+		return l.tags.setLinkTags(tx, link.ID, link.Tags)
+	})
 }
 
 // Delete method archives record in link table by id.
 func (l *Links) Delete(id int, time time.Time) (*model.Link, error) {
+	if err := l.checkWrite(id); err != nil {
+		return nil, err
+	}
 	values := []interface{}{
 		time,
 		id,
@@ -111,28 +395,65 @@ func (l *Links) Delete(id int, time time.Time) (*model.Link, error) {
 	if err != nil {
 		return nil, err
 	}
-	return l.Get(id)
+	return l.withInactive().Get(id)
+}
+
+// Restore method activates archived record in link table by id.
+func (l *Links) Restore(id int) (*model.Link, error) {
+	if err := l.checkWrite(id); err != nil {
+		return nil, err
+	}
+	err := l.records.update([]interface{}{id}, l.restorePattern)
+	if err != nil {
+		return nil, err
+	}
+	return l.withInactive().Get(id)
 }
 
 // Retrieve method selects from link table records by sended filers.
+// Filter sort (e.g. sort=c_name,-rating) orders records by one of sortFields,
+// field prefixed by - is sorted in descending order. Filters limit, offset
+// and after select one page of records, after is id of last record of
+// previous page and can be used only with default order or order by l_id.
 func (l *Links) Retrieve(filters map[string][]string) ([]*model.Link, error) {
-	whereClause, values, err := l.buildFilters(filters)
+	filters, sortValues := popFilter(filters, "sort")
+	filters, page, err := popPage(filters)
 	if err != nil {
 		return nil, err
 	}
-	var rows *sql.Rows
-	if len(whereClause) != 0 {
-		query := l.selectPattern + "WHERE " + whereClause
-		rows, err = l.records.db.Query(query, values...)
+	order, err := parseSort(sortValues, l.sortFields)
+	if err != nil {
+		return nil, err
+	}
+	whereClause, values, err := l.where(filters)
+	if err != nil {
+		return nil, err
+	}
+	if page.After > 0 {
+		descending, err := cursorDescending(order, "l_id")
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		rows, err = l.records.db.Query(l.selectPattern)
-		if err != nil {
-			return nil, err
+		condition := "l.id > ?"
+		if descending {
+			condition = "l.id < ?"
 		}
+		whereClause = andConditions(whereClause, condition)
+		values = append(values, page.After)
+	}
+	query := l.selectPattern
+	if len(whereClause) != 0 {
+		query += "WHERE " + whereClause
 	}
+	query += " ORDER BY " + buildOrderBy(withTiebreaker(order, "l_id"))
+	limit, limitValues := buildLimit(page)
+	query += limit
+	values = append(values, limitValues...)
+	rows, err := l.records.db.Query(query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 	var result []*model.Link
 	for rows.Next() {
 		link, err := l.scanRow(rows.Scan)
@@ -141,67 +462,84 @@ func (l *Links) Retrieve(filters map[string][]string) ([]*model.Link, error) {
 		}
 		result = append(result, link)
 	}
-	return result, nil
+	return result, rows.Err()
 }
 
-// buildFilters creates sql filter and prepare values from request input.
-func (l *Links) buildFilters(filters map[string][]string) (string, []interface{}, error) {
-	if len(filters) == 0 {
-		return "", nil, nil
+// Count method returns number of records matching filters, sort and page
+// filters are ignored.
+func (l *Links) Count(filters map[string][]string) (int, error) {
+	filters, _ = popFilter(filters, "sort")
+	filters, _, err := popPage(filters)
+	if err != nil {
+		return 0, err
+	}
+	whereClause, values, err := l.where(filters)
+	if err != nil {
+		return 0, err
+	}
+	query := l.countPattern
+	if len(whereClause) != 0 {
+		query += "WHERE " + whereClause
+	}
+	var count int
+	if err := l.records.db.QueryRow(query, values...).Scan(&count); err != nil {
+		return 0, err
 	}
+	return count, nil
+}
 
-	var filter string
-	var outValues []interface{}
-	operator := " "
-	for field, values := range filters {
-		if len(filter) > 0 {
-			operator = " AND "
-		}
-		field = strings.ToLower(field)
-		if !l.isFieldAllowed(field) {
-			return "", nil, fmt.Errorf("Field %s is not allowed", field)
+// where creates sql condition from filters limited to links visible for viewer.
+// Filter tag selects links with any of tags, with tag_match=all links with
+// all of them.
+func (l *Links) where(filters map[string][]string) (string, []interface{}, error) {
+	filters, tags, matchAll, err := popTagFilter(filters)
+	if err != nil {
+		return "", nil, err
+	}
+	whereClause, values, err := l.buildFilters(filters)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(tags) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+		for _, tag := range tags {
+			values = append(values, tag)
 		}
-		if len(values) == 1 {
-			filter += operator + correctFieldName(field) + " = ?"
+		if matchAll {
+			whereClause = andConditions(whereClause, fmt.Sprintf(l.tagAllPattern, placeholders))
+			values = append(values, len(tags))
 		} else {
-			inClauseVals := strings.Repeat("?, ", len(values))
-			filter += operator + correctFieldName(field) + " IN (" +
-				inClauseVals[:len(inClauseVals)-2] + ")"
-			for _, val := range values {
-				typedValue, err := l.convertValue(field, val)
-				if err != nil {
-					return "", nil, err
-				}
-				outValues = append(outValues, typedValue)
-			}
+			whereClause = andConditions(whereClause, fmt.Sprintf(l.tagAnyPattern, placeholders))
 		}
 	}
-	return filter, outValues, nil
-}
-
-// correctFieldName repairs field name, replace _ by .
-func correctFieldName(field string) string {
-	return strings.Replace(field, "_", ".", 1)
+	if visibility, visibilityValues := l.visibility(); visibility != "" {
+		whereClause = andConditions(whereClause, visibility)
+		values = append(values, visibilityValues...)
+	}
+	return whereClause, values, nil
 }
 
-// isFieldAllowed checks if allowed field comes from request.
-func (l *Links) isFieldAllowed(field string) bool {
-	sort.Strings(l.fieldsForSelect)
-	idx := sort.SearchStrings(l.fieldsForSelect, field)
-	return idx != len(l.fieldsForSelect) && l.fieldsForSelect[idx] == field
+// buildFilters creates sql filter and prepare values from request input.
+func (l *Links) buildFilters(filters map[string][]string) (string, []interface{}, error) {
+	return buildFilters(filters, l.fieldsForSelect, l.convertValue)
 }
 
 // scanRow fills link structure with values from db record.
 func (l *Links) scanRow(fn scanner) (*model.Link, error) {
 	link := &model.Link{}
 	category := &model.Category{}
+	var tags sql.NullString
 	err := fn(&link.ID, &link.Link, &link.Name, &link.Active, &link.Created,
 		&category.ID, &category.Name, &category.ParentID, &category.Active,
-		&category.Created)
+		&category.Created, &link.Rating, &link.RatingCount, &tags)
 	if err != nil {
 		return nil, err
 	}
 	link.Category = category
+	if tags.Valid && tags.String != "" {
+		link.Tags = strings.Split(tags.String, ",")
+		sort.Strings(link.Tags)
+	}
 	return link, nil
 }
 
@@ -226,8 +564,3 @@ func (l *Links) convertValue(field string, value string) (interface{}, error) {
 	}
 	return nil, fmt.Errorf("Unknown field %s", field)
 }
-
-// Close db connection
-func (l *Links) Close() error {
-	return l.records.close()
-}
@@ -1,6 +1,8 @@
 package datalayer
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -31,41 +33,49 @@ func createLink(id int, name string) *model.Link {
 
 func createMockGetExpectedQuery(mock sqlmock.Sqlmock, link *model.Link, id int) {
 	columns := []string{"l_id", "link", "l_name", "l_active", "l_created", "c_id",
-		"c_name", "parent_id", "c_active", "c_created"}
-	mock.ExpectQuery("^SELECT (.+) FROM link l JOIN category c on l.category_id = c.id WHERE l.id = ?").
+		"c_name", "parent_id", "c_active", "c_created", "rating", "rating_count", "tags"}
+	mock.ExpectQuery("^SELECT (.+) FROM link l JOIN category c on l.category_id = c.id (.+) WHERE l.id = ?").
 		WithArgs(id).
 		WillReturnRows(sqlmock.NewRows(columns).AddRow(
 			link.ID, link.Link, link.Name, link.Active,
 			link.Created, link.Category.ID, link.Category.Name,
-			link.Category.ParentID, link.Category.Active, link.Category.Created))
+			link.Category.ParentID, link.Category.Active, link.Category.Created,
+			link.Rating, link.RatingCount, nil))
 }
 
 func createMockRetrieveExpectedQuery(mock sqlmock.Sqlmock, links []*model.Link) {
 	columns := []string{"l_id", "link", "l_name", "l_active", "l_created", "c_id",
-		"c_name", "parent_id", "c_active", "c_created"}
+		"c_name", "parent_id", "c_active", "c_created", "rating", "rating_count", "tags"}
 	rows := sqlmock.NewRows(columns)
 	for _, link := range links {
 		rows.AddRow(link.ID, link.Link, link.Name, link.Active,
 			link.Created, link.Category.ID, link.Category.Name,
-			link.Category.ParentID, link.Category.Active, link.Category.Created)
+			link.Category.ParentID, link.Category.Active, link.Category.Created,
+			link.Rating, link.RatingCount, nil)
 	}
-	mock.ExpectQuery("^SELECT (.+) FROM link l JOIN category c on l.category_id = c.id "+
+	mock.ExpectQuery("^SELECT (.+) FROM link l JOIN category c on l.category_id = c.id (.+) "+
 		"WHERE l.id IN \\(\\?, \\?\\) AND l.name IN \\(\\?, \\?\\)").
 		WithArgs(1, 3, "tenis", "fotbal").
 		WillReturnRows(rows)
 }
 
+func createMockDuplicateExpectedQuery(mock sqlmock.Sqlmock, link *model.Link) {
+	mock.ExpectQuery("^SELECT id FROM link WHERE canonical_hash = \\? AND id <> \\? ORDER BY id$").
+		WithArgs(canonicalHash(link.Link), link.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
 func createMockInsertExpectedQuery(mock sqlmock.Sqlmock, link *model.Link, id int) {
-	mock.ExpectPrepare("^INSERT INTO link\\(link, name, category_id\\) VALUES\\(\\?, \\?, \\?\\)").
+	mock.ExpectPrepare("^INSERT INTO link\\(link, name, category_id, canonical_hash\\) VALUES\\(\\?, \\?, \\?, \\?\\)").
 		ExpectExec().
-		WithArgs(link.Link, link.Name, link.Category.ID).
+		WithArgs(link.Link, link.Name, link.Category.ID, canonicalHash(link.Link)).
 		WillReturnResult(sqlmock.NewResult(int64(id), 1))
 }
 
 func createMockUpdateExpectedQuery(mock sqlmock.Sqlmock, link *model.Link) {
-	mock.ExpectPrepare("^UPDATE link SET link=\\?, name=\\?, category_id=\\? WHERE id=\\?").
+	mock.ExpectPrepare("^UPDATE link SET link=\\?, name=\\?, category_id=\\?, canonical_hash=\\? WHERE id=\\?").
 		ExpectExec().
-		WithArgs(link.Link, link.Name, link.Category.ID, link.ID).
+		WithArgs(link.Link, link.Name, link.Category.ID, canonicalHash(link.Link), link.ID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 }
 
@@ -82,7 +92,7 @@ func TestLinkGetShouldReturnRecord(t *testing.T) {
 	expectedLink := createLink(id, "link 1")
 	createMockGetExpectedQuery(mock, expectedLink, id)
 	links := CreateLinks(db)
-	defer links.Close()
+	defer db.Close()
 	link, err := links.Get(id)
 	if err != nil {
 		t.Errorf("Links.Get[%d] should return result, but error: %v", id, err)
@@ -100,10 +110,11 @@ func TestLinkSaveShouldInsertRecord(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	link := createLink(0, "link 1")
 	id := 1
+	createMockDuplicateExpectedQuery(mock, link)
 	createMockInsertExpectedQuery(mock, link, id)
 	createMockGetExpectedQuery(mock, link, id)
 	links := CreateLinks(db)
-	defer links.Close()
+	defer db.Close()
 	outputLink, err := links.Save(*link)
 	if err != nil {
 		t.Errorf("Links.Save[%#v] should insert record, but error: %v", link, err)
@@ -122,10 +133,11 @@ func TestLinkSaveShouldUpdateRecord(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	id := 1
 	link := createLink(id, "link 1")
+	createMockDuplicateExpectedQuery(mock, link)
 	createMockUpdateExpectedQuery(mock, link)
 	createMockGetExpectedQuery(mock, link, id)
 	links := CreateLinks(db)
-	defer links.Close()
+	defer db.Close()
 	outputLink, err := links.Save(*link)
 	if err != nil {
 		t.Errorf("Links.Save[%#v] should update record, but error: %v", link, err)
@@ -148,7 +160,7 @@ func TestLinkDeleteShouldArchiveRecord(t *testing.T) {
 	createMockDeleteExpectedQuery(mock, link)
 	createMockGetExpectedQuery(mock, link, id)
 	links := CreateLinks(db)
-	defer links.Close()
+	defer db.Close()
 	outputLink, err := links.Delete(id, now)
 	if err != nil {
 		t.Errorf("Links.Delete[%d, %s] should archive record, but error: %v",
@@ -163,6 +175,29 @@ func TestLinkDeleteShouldArchiveRecord(t *testing.T) {
 	}
 }
 
+func TestLinkRestoreShouldActivateRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	link := createLink(id, "link 1")
+	mock.ExpectPrepare("^UPDATE link SET active=NULL WHERE id=\\?").
+		ExpectExec().
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	createMockGetExpectedQuery(mock, link, id)
+	links := CreateLinks(db)
+	defer db.Close()
+	outputLink, err := links.Restore(id)
+	if err != nil {
+		t.Errorf("Links.Restore[%d] should activate record, but error: %v", id, err)
+	}
+	if !cmp.Equal(outputLink, link) {
+		t.Errorf("Links object are different: %#v, %#v", outputLink, link)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestLinkRetriveShouldReturnRecords(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	links := make([]*model.Link, 2)
@@ -170,7 +205,7 @@ func TestLinkRetriveShouldReturnRecords(t *testing.T) {
 	links[1] = createLink(3, "fotbal")
 	createMockRetrieveExpectedQuery(mock, links)
 	linksObj := CreateLinks(db)
-	defer linksObj.Close()
+	defer db.Close()
 	filters := make(map[string][]string)
 	filters["l_id"] = []string{"1", "3"}
 	filters["l_name"] = []string{"tenis", "fotbal"}
@@ -187,3 +222,303 @@ func TestLinkRetriveShouldReturnRecords(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestLinkGetForUserShouldApplyVisibility(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	expectedLink := createLink(id, "link 1")
+	columns := []string{"l_id", "link", "l_name", "l_active", "l_created", "c_id",
+		"c_name", "parent_id", "c_active", "c_created", "rating", "rating_count", "tags"}
+	mock.ExpectQuery("^SELECT (.+) FROM link l JOIN category c on l.category_id = c.id (.+) "+
+		"WHERE l.id = \\? AND l.active IS NULL AND c.active IS NULL AND \\(EXISTS \\(SELECT 1 FROM user_link ul (.+)\\) "+
+		"OR EXISTS \\(SELECT 1 FROM role_link rl (.+)\\)\\)").
+		WithArgs(id, 5, 5).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			expectedLink.ID, expectedLink.Link, expectedLink.Name, expectedLink.Active,
+			expectedLink.Created, expectedLink.Category.ID, expectedLink.Category.Name,
+			expectedLink.Category.ParentID, expectedLink.Category.Active,
+			expectedLink.Category.Created, expectedLink.Rating, expectedLink.RatingCount, nil))
+	links := CreateLinks(db)
+	defer db.Close()
+	link, err := links.ForUser(&model.User{ID: 5}).Get(id)
+	if err != nil {
+		t.Errorf("Links.Get[%d] should return result, but error: %v", id, err)
+	}
+	same := cmp.Equal(expectedLink, link)
+	if !same {
+		t.Errorf("Links object are different: %#v, %#v", expectedLink, link)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkRetrieveForSuperadminShouldNotApplyVisibility(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	links := make([]*model.Link, 2)
+	links[0] = createLink(1, "tenis")
+	links[1] = createLink(3, "fotbal")
+	createMockRetrieveExpectedQuery(mock, links)
+	linksObj := CreateLinks(db).ForUser(&model.User{ID: 5, Superadmin: true})
+	defer db.Close()
+	filters := make(map[string][]string)
+	filters["l_id"] = []string{"1", "3"}
+	filters["l_name"] = []string{"tenis", "fotbal"}
+	outputLinks, err := linksObj.Retrieve(filters)
+	if err != nil {
+		t.Errorf("Links.Retrieve[%v] should retrieve records, but error: %v",
+			filters, err)
+	}
+	same := cmp.Equal(outputLinks, links)
+	if !same {
+		t.Errorf("Links object are different: %#v, %#v", outputLinks, links)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkSaveForUserShouldCreateOwner(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	link := createLink(0, "link 1")
+	id := 1
+	createMockDuplicateExpectedQuery(mock, link)
+	mock.ExpectBegin()
+	createMockInsertExpectedQuery(mock, link, id)
+	mock.ExpectPrepare("^INSERT INTO user_link\\(user_id, link_id, owner\\) VALUES\\(\\?, \\?, \\?\\)").
+		ExpectExec().
+		WithArgs(5, id, true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT (.+) FROM link l JOIN category c on l.category_id = c.id (.+) WHERE l.id = \\? AND (.+)").
+		WithArgs(id, 5, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"l_id", "link", "l_name", "l_active", "l_created",
+			"c_id", "c_name", "parent_id", "c_active", "c_created", "rating", "rating_count", "tags"}).AddRow(
+			id, link.Link, link.Name, link.Active, link.Created, link.Category.ID,
+			link.Category.Name, link.Category.ParentID, link.Category.Active,
+			link.Category.Created, link.Rating, link.RatingCount, nil))
+	links := CreateLinks(db).ForUser(&model.User{ID: 5})
+	defer db.Close()
+	outputLink, err := links.Save(*link)
+	if err != nil {
+		t.Errorf("Links.Save[%#v] should insert record, but error: %v", link, err)
+	}
+	link.ID = id
+	same := cmp.Equal(outputLink, link)
+	if !same {
+		t.Errorf("Links object are different: %#v, %#v", outputLink, link)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkDeleteForNotOwnerShouldBeForbidden(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_link WHERE link_id = \\? AND user_id = \\? AND owner = \\?").
+		WithArgs(1, 5, true).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	links := CreateLinks(db).ForUser(&model.User{ID: 5})
+	defer db.Close()
+	_, err := links.Delete(1, time.Now())
+	if err != ErrForbidden {
+		t.Errorf("Links.Delete[%d] should be forbidden, but error: %v", 1, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkRetrieveShouldSortByRating(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	links := make([]*model.Link, 2)
+	links[0] = createLink(3, "fotbal")
+	links[0].Rating = 4.5
+	links[0].RatingCount = 2
+	links[1] = createLink(1, "tenis")
+	links[1].Rating = 3
+	links[1].RatingCount = 1
+	columns := []string{"l_id", "link", "l_name", "l_active", "l_created", "c_id",
+		"c_name", "parent_id", "c_active", "c_created", "rating", "rating_count", "tags"}
+	rows := sqlmock.NewRows(columns)
+	for _, link := range links {
+		rows.AddRow(link.ID, link.Link, link.Name, link.Active,
+			link.Created, link.Category.ID, link.Category.Name,
+			link.Category.ParentID, link.Category.Active, link.Category.Created,
+			link.Rating, link.RatingCount, nil)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM link l (.+) WHERE l.name IN \\(\\?, \\?\\) "+
+		"AND l.active IS NULL AND c.active IS NULL ORDER BY rating DESC, l_id ASC$").
+		WithArgs("tenis", "fotbal").
+		WillReturnRows(rows)
+	linksObj := CreateLinks(db)
+	defer db.Close()
+	filters := make(map[string][]string)
+	filters["l_name"] = []string{"tenis", "fotbal"}
+	filters["sort"] = []string{"-rating"}
+	outputLinks, err := linksObj.Retrieve(filters)
+	if err != nil {
+		t.Errorf("Links.Retrieve[%v] should retrieve records, but error: %v",
+			filters, err)
+	}
+	same := cmp.Equal(outputLinks, links)
+	if !same {
+		t.Errorf("Links object are different: %#v, %#v", outputLinks, links)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkRetrieveShouldRejectUnknownSortField(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	linksObj := CreateLinks(db)
+	defer db.Close()
+	filters := make(map[string][]string)
+	filters["sort"] = []string{"password"}
+	_, err := linksObj.Retrieve(filters)
+	if err == nil {
+		t.Errorf("Links.Retrieve[%v] should reject sort field", filters)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkRetrieveShouldReturnPage(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	link := createLink(3, "fotbal")
+	columns := []string{"l_id", "link", "l_name", "l_active", "l_created", "c_id",
+		"c_name", "parent_id", "c_active", "c_created", "rating", "rating_count", "tags"}
+	rows := sqlmock.NewRows(columns).AddRow(link.ID, link.Link, link.Name, link.Active,
+		link.Created, link.Category.ID, link.Category.Name,
+		link.Category.ParentID, link.Category.Active, link.Category.Created,
+		link.Rating, link.RatingCount, nil)
+	mock.ExpectQuery("^SELECT (.+) FROM link l (.+) WHERE l.active IS NULL AND c.active IS NULL AND l.id < \\? "+
+		"ORDER BY l_id DESC LIMIT \\? OFFSET \\?$").
+		WithArgs(5, 10, 0).
+		WillReturnRows(rows)
+	linksObj := CreateLinks(db)
+	defer db.Close()
+	filters := make(map[string][]string)
+	filters["sort"] = []string{"-l_id"}
+	filters["limit"] = []string{"10"}
+	filters["after"] = []string{"5"}
+	outputLinks, err := linksObj.Retrieve(filters)
+	if err != nil {
+		t.Errorf("Links.Retrieve[%v] should retrieve records, but error: %v", filters, err)
+	}
+	if !cmp.Equal(outputLinks, []*model.Link{link}) {
+		t.Errorf("Links object are different: %#v, %#v", outputLinks, link)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkRetrieveShouldRejectInvalidPage(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	linksObj := CreateLinks(db)
+	defer db.Close()
+	for _, filters := range []map[string][]string{
+		{"limit": {"ten"}},
+		{"offset": {"-1"}},
+		{"offset": {"10"}, "after": {"5"}},
+		{"sort": {"c_name"}, "after": {"5"}},
+	} {
+		_, err := linksObj.Retrieve(filters)
+		if !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Links.Retrieve[%v] should reject page, but error: %v", filters, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkCountShouldIgnorePage(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM link l JOIN category c on l.category_id = c.id " +
+		"WHERE l.name = \\? AND l.active IS NULL AND c.active IS NULL$").
+		WithArgs("tenis").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	linksObj := CreateLinks(db)
+	defer db.Close()
+	filters := map[string][]string{"l_name": {"tenis"}, "sort": {"-rating"}, "limit": {"2"}, "offset": {"4"}}
+	count, err := linksObj.Count(filters)
+	if err != nil || count != 7 {
+		t.Errorf("Links.Count[%v] should return 7, but returns: %d, %v", filters, count, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkSaveShouldRejectDuplicateOfVisibleURL(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	link := createLink(0, "link 1")
+	mock.ExpectQuery("^SELECT id FROM link WHERE canonical_hash = \\? AND id <> \\? ORDER BY id$").
+		WithArgs(canonicalHash(link.Link), 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(7))
+	// link 5 is archived or not visible
+	mock.ExpectQuery("^SELECT (.+) FROM link l JOIN category c on l.category_id = c.id (.+) " +
+		"WHERE l.id = \\? AND l.active IS NULL AND c.active IS NULL$").
+		WithArgs(5).
+		WillReturnError(sql.ErrNoRows)
+	createMockGetExpectedQuery(mock, createLink(7, "link 1"), 7)
+	links := CreateLinks(db)
+	defer db.Close()
+	_, err := links.Save(*link)
+	var duplicateErr *DuplicateLinkError
+	if !errors.As(err, &duplicateErr) || duplicateErr.ExistingID != 7 {
+		t.Errorf("Links.Save[%#v] should fail with duplicate of link 7, but error: %v", link, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkPatchShouldUpdateOnlyChangedColumns(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	link := createLink(id, "renamed")
+	mock.ExpectPrepare("^UPDATE link SET name=\\? WHERE id=\\?$").
+		ExpectExec().
+		WithArgs("renamed", id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	createMockGetExpectedQuery(mock, link, id)
+	links := CreateLinks(db)
+	defer db.Close()
+	name := "renamed"
+	outputLink, err := links.Patch(id, LinkPatch{Name: &name})
+	if err != nil {
+		t.Errorf("Links.Patch[%d] should update name, but error: %v", id, err)
+	}
+	if !cmp.Equal(outputLink, link) {
+		t.Errorf("Links object are different: %#v, %#v", outputLink, link)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLinkPatchShouldUpdateCanonicalHashWithURL(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	link := createLink(id, "link 1")
+	createMockDuplicateExpectedQuery(mock, link)
+	mock.ExpectPrepare("^UPDATE link SET link=\\?, canonical_hash=\\?, category_id=\\? WHERE id=\\?$").
+		ExpectExec().
+		WithArgs(link.Link, canonicalHash(link.Link), 2, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	createMockGetExpectedQuery(mock, link, id)
+	links := CreateLinks(db)
+	defer db.Close()
+	categoryID := 2
+	if _, err := links.Patch(id, LinkPatch{Link: &link.Link, CategoryID: &categoryID}); err != nil {
+		t.Errorf("Links.Patch[%d] should update url and category, but error: %v", id, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
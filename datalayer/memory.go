@@ -0,0 +1,224 @@
+package datalayer
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryDB holds tables of in-memory store. All repositories of one store
+// share it and its lock, so they are safe for concurrent use.
+type memoryDB struct {
+	mu         sync.RWMutex
+	lastIDs    map[string]int
+	categories map[int]model.Category
+	links      map[int]memoryLink
+	users      map[int]memoryUser
+	roles      map[int]model.Role
+	userRoles  map[int]memoryUserRole
+	userLinks  []memoryUserLink
+	roleLinks  []memoryRoleLink
+	sessions   map[string]memorySession
+	stars      map[int]memoryStar
+	notes      map[int]memoryNote
+	tags       map[int]model.Tag
+	linkTags   []memoryLinkTag
+}
+
+// memoryLink is row of link table.
+type memoryLink struct {
+	model.Link
+	categoryID int
+	// canonicalHash is empty for links saved as allowed duplicates
+	canonicalHash string
+}
+
+// memoryUser is row of user table.
+type memoryUser struct {
+	model.User
+	password string
+}
+
+// memoryUserRole is row of user_role table.
+type memoryUserRole struct {
+	id     int
+	userID int
+	roleID int
+}
+
+// memoryUserLink is row of user_link table.
+type memoryUserLink struct {
+	userID int
+	linkID int
+	owner  bool
+}
+
+// memoryRoleLink is row of role_link table.
+type memoryRoleLink struct {
+	id     int
+	roleID int
+	linkID int
+}
+
+// memorySession is row of session table.
+type memorySession struct {
+	userID  int
+	expires time.Time
+}
+
+// memoryStar is row of star table.
+type memoryStar struct {
+	id      int
+	userID  int
+	linkID  int
+	stars   int
+	created time.Time
+}
+
+// memoryNote is row of note table.
+type memoryNote struct {
+	id      int
+	userID  int
+	linkID  int
+	note    string
+	private bool
+	created time.Time
+}
+
+// memoryLinkTag is row of link_tag table.
+type memoryLinkTag struct {
+	linkID int
+	tagID  int
+}
+
+// CreateMemoryStore creates and returns repositories which keep all data
+// in memory. It is meant for tests and for trying the application out.
+func CreateMemoryStore() *Store {
+	db := &memoryDB{
+		lastIDs:    make(map[string]int),
+		categories: make(map[int]model.Category),
+		links:      make(map[int]memoryLink),
+		users:      make(map[int]memoryUser),
+		roles:      make(map[int]model.Role),
+		userRoles:  make(map[int]memoryUserRole),
+		sessions:   make(map[string]memorySession),
+		stars:      make(map[int]memoryStar),
+		notes:      make(map[int]memoryNote),
+		tags:       make(map[int]model.Tag),
+	}
+	return &Store{
+		Links:      &memoryLinks{db: db, columns: CreateLinks(nil)},
+		Categories: &memoryCategories{db: db, columns: CreateCategories(nil)},
+		Users:      &memoryUsers{db: db, columns: CreateUsers(nil)},
+		Roles:      &memoryRoles{db: db, columns: CreateRoles(nil)},
+		UserRoles:  &memoryUserRoles{db: db},
+		Sessions:   &memorySessions{db: db},
+		Stars:      &memoryStars{db: db},
+		Notes:      &memoryNotes{db: db},
+		Tags:       &memoryTags{db: db},
+	}
+}
+
+// nextID returns next auto increment id of table, caller must hold write lock.
+func (m *memoryDB) nextID(table string) int {
+	m.lastIDs[table]++
+	return m.lastIDs[table]
+}
+
+// createdNow returns current time, it is default value of created columns.
+func createdNow() *time.Time {
+	t := time.Now()
+	return &t
+}
+
+// memoryFilters type is filters from request converted to typed values.
+type memoryFilters []filterCondition
+
+// convertFilters validates filters and converts their values in the same way
+// as buildFilters does for sql repositories.
+func convertFilters(filters map[string][]string, allowedFields []string,
+	convertValue func(field string, value string) (interface{}, error)) (memoryFilters, error) {
+	return parseFilters(filters, allowedFields, convertValue)
+}
+
+// match checks if record matches all filters, value returns value of field
+// of the record.
+func (f memoryFilters) match(value func(field string) interface{}) bool {
+	for _, condition := range f {
+		if !condition.match(value(condition.field)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNullValue checks if record value is null.
+func isNullValue(recordValue interface{}) bool {
+	if t, ok := recordValue.(*time.Time); ok {
+		return t == nil
+	}
+	return recordValue == nil
+}
+
+// equalValues compares record value with typed filter value.
+func equalValues(recordValue interface{}, filterValue interface{}) bool {
+	if t, ok := recordValue.(*time.Time); ok {
+		if t == nil {
+			return false
+		}
+		recordValue = *t
+	}
+	if t, ok := recordValue.(time.Time); ok {
+		filterTime, ok := filterValue.(time.Time)
+		return ok && t.Equal(filterTime)
+	}
+	return recordValue == filterValue
+}
+
+// compareValues compares record values of the same field, returns negative
+// number when a goes before b. Null values go first, like in sql.
+func compareValues(a interface{}, b interface{}) int {
+	if t, ok := a.(*time.Time); ok {
+		a = nil
+		if t != nil {
+			a = *t
+		}
+	}
+	if t, ok := b.(*time.Time); ok {
+		b = nil
+		if t != nil {
+			b = *t
+		}
+	}
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch a := a.(type) {
+	case int:
+		return compareFloats(float64(a), float64(b.(int)))
+	case float64:
+		return compareFloats(a, b.(float64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return compareFloats(float64(a.UnixNano()), float64(b.(time.Time).UnixNano()))
+	}
+	return 0
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,186 @@
+package datalayer
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryCategories type is in-memory implementation of CategoryStore.
+type memoryCategories struct {
+	db      *memoryDB
+	columns *Categories
+}
+
+// Get method returns category by id.
+func (c *memoryCategories) Get(id int) (*model.Category, error) {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+	category, ok := c.db.categories[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &category, nil
+}
+
+// Save method inserts/updates category.
+func (c *memoryCategories) Save(category model.Category) (*model.Category, error) {
+	if err := checkParent(c, category); err != nil {
+		return nil, err
+	}
+	c.db.mu.Lock()
+	if category.ID > 0 {
+		stored, ok := c.db.categories[category.ID]
+		if ok {
+			stored.Name = category.Name
+			stored.ParentID = category.ParentID
+			c.db.categories[category.ID] = stored
+		}
+	} else {
+		category = model.Category{ID: c.db.nextID("category"), Name: category.Name,
+			ParentID: category.ParentID, Created: createdNow()}
+		c.db.categories[category.ID] = category
+	}
+	c.db.mu.Unlock()
+	return c.Get(category.ID)
+}
+
+// Delete method archives category by id.
+func (c *memoryCategories) Delete(id int, time time.Time) (*model.Category, error) {
+	c.db.mu.Lock()
+	c.db.archiveCategory(id, time)
+	c.db.mu.Unlock()
+	return c.Get(id)
+}
+
+// DeleteCascade method archives category by id together with its active
+// subcategories and active links of the subtree.
+func (c *memoryCategories) DeleteCascade(id int, time time.Time) (*model.Category, error) {
+	ids, err := c.DescendantIDs(id)
+	if err != nil {
+		return nil, err
+	}
+	subtree := make(map[int]bool, len(ids))
+	for _, categoryID := range ids {
+		subtree[categoryID] = true
+	}
+	c.db.mu.Lock()
+	for linkID, link := range c.db.links {
+		if subtree[link.categoryID] && link.Active == nil {
+			link.Active = &time
+			c.db.links[linkID] = link
+		}
+	}
+	for _, categoryID := range ids[1:] {
+		if category, ok := c.db.categories[categoryID]; ok && category.Active == nil {
+			c.db.archiveCategory(categoryID, time)
+		}
+	}
+	c.db.archiveCategory(id, time)
+	c.db.mu.Unlock()
+	return c.Get(id)
+}
+
+// archiveCategory sets active time of category, caller must hold write lock.
+func (m *memoryDB) archiveCategory(id int, time time.Time) {
+	if category, ok := m.categories[id]; ok {
+		category.Active = &time
+		m.categories[id] = category
+	}
+}
+
+// ActiveLinksCount method returns number of active links in category by id
+// and in all its subcategories.
+func (c *memoryCategories) ActiveLinksCount(id int) (int, error) {
+	ids, err := c.DescendantIDs(id)
+	if err != nil {
+		return 0, err
+	}
+	subtree := make(map[int]bool, len(ids))
+	for _, categoryID := range ids {
+		subtree[categoryID] = true
+	}
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+	count := 0
+	for _, link := range c.db.links {
+		if subtree[link.categoryID] && link.Active == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Retrieve method returns categories matching filters.
+func (c *memoryCategories) Retrieve(filters map[string][]string) ([]*model.Category, error) {
+	typedFilters, err := convertFilters(filters, c.columns.fieldsForSelect, c.columns.convertValue)
+	if err != nil {
+		return nil, err
+	}
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+	var result []*model.Category
+	for _, category := range c.db.categories {
+		category := category
+		if typedFilters.match(func(field string) interface{} { return categoryValue(&category, field) }) {
+			result = append(result, &category)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// categoryValue returns value of category filter field.
+func categoryValue(category *model.Category, field string) interface{} {
+	switch field {
+	case "c_id":
+		return category.ID
+	case "c_name":
+		return category.Name
+	case "parent_id":
+		return category.ParentID
+	case "c_active":
+		return category.Active
+	case "c_created":
+		return category.Created
+	}
+	return nil
+}
+
+// Subtree method returns category by id with all its subcategories.
+func (c *memoryCategories) Subtree(id int) (*model.CategoryNode, error) {
+	categories, err := c.Retrieve(nil)
+	if err != nil {
+		return nil, err
+	}
+	return subtree(categories, id)
+}
+
+// Path method returns ancestors of category by id.
+func (c *memoryCategories) Path(id int) ([]*model.Category, error) {
+	categories, err := c.Retrieve(nil)
+	if err != nil {
+		return nil, err
+	}
+	return categoryPath(categories, id)
+}
+
+// Tree method returns all root categories with their subcategories.
+func (c *memoryCategories) Tree() ([]*model.CategoryNode, error) {
+	categories, err := c.Retrieve(nil)
+	if err != nil {
+		return nil, err
+	}
+	return tree(categories)
+}
+
+// DescendantIDs method returns id of category and ids of all its subcategories.
+func (c *memoryCategories) DescendantIDs(id int) ([]int, error) {
+	node, err := c.Subtree(id)
+	if err != nil {
+		return nil, err
+	}
+	return descendantIDs(node), nil
+}
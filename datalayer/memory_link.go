@@ -0,0 +1,498 @@
+package datalayer
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryLinks type is in-memory implementation of LinkStore.
+type memoryLinks struct {
+	db              *memoryDB
+	columns         *Links
+	viewer          *model.User
+	includeInactive bool
+	allowDuplicate  bool
+}
+
+// ForUser method returns links limited to links visible for user.
+func (l *memoryLinks) ForUser(user *model.User) LinkStore {
+	scoped := *l
+	scoped.viewer = user
+	return &scoped
+}
+
+// IncludeInactive method returns links which contain also archived links and
+// links in archived categories.
+func (l *memoryLinks) IncludeInactive() LinkStore {
+	return l.withInactive()
+}
+
+// AllowDuplicate method returns links which save links even when link with
+// the same canonical url already exists.
+func (l *memoryLinks) AllowDuplicate() LinkStore {
+	scoped := *l
+	scoped.allowDuplicate = true
+	return &scoped
+}
+
+func (l *memoryLinks) withInactive() *memoryLinks {
+	scoped := *l
+	scoped.includeInactive = true
+	return &scoped
+}
+
+// CanWrite method checks if viewer is allowed to change link by id.
+func (l *memoryLinks) CanWrite(id int) (bool, error) {
+	if l.viewer == nil || l.viewer.Superadmin {
+		return true, nil
+	}
+	l.db.mu.RLock()
+	defer l.db.mu.RUnlock()
+	for _, userLink := range l.db.userLinks {
+		if userLink.linkID == id && userLink.userID == l.viewer.ID && userLink.owner {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Get method returns link by id.
+func (l *memoryLinks) Get(id int) (*model.Link, error) {
+	l.db.mu.RLock()
+	defer l.db.mu.RUnlock()
+	stored, ok := l.db.links[id]
+	if !ok || !l.visible(id) {
+		return nil, sql.ErrNoRows
+	}
+	link, ok := l.db.joinLink(stored)
+	if !ok || !l.active(link) {
+		return nil, sql.ErrNoRows
+	}
+	return link, nil
+}
+
+// active checks if link is shown, archived links and links in archived
+// categories are shown only when inactive are included.
+func (l *memoryLinks) active(link *model.Link) bool {
+	return l.includeInactive || (link.Active == nil && link.Category.Active == nil)
+}
+
+// visible checks if link is visible for viewer, caller must hold lock.
+func (l *memoryLinks) visible(linkID int) bool {
+	if l.viewer == nil || l.viewer.Superadmin {
+		return true
+	}
+	for _, userLink := range l.db.userLinks {
+		if userLink.linkID == linkID && userLink.userID == l.viewer.ID {
+			return true
+		}
+	}
+	for _, roleLink := range l.db.roleLinks {
+		if roleLink.linkID != linkID {
+			continue
+		}
+		for _, userRole := range l.db.userRoles {
+			if userRole.roleID == roleLink.roleID && userRole.userID == l.viewer.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinLink returns link with its category and rating, caller must hold lock.
+func (m *memoryDB) joinLink(stored memoryLink) (*model.Link, bool) {
+	category, ok := m.categories[stored.categoryID]
+	if !ok {
+		return nil, false
+	}
+	link := stored.Link
+	link.Category = &category
+	link.Tags = m.linkTagNames(link.ID)
+	total := 0
+	for _, star := range m.stars {
+		if star.linkID == link.ID {
+			total += star.stars
+			link.RatingCount++
+		}
+	}
+	if link.RatingCount > 0 {
+		link.Rating = float64(total) / float64(link.RatingCount)
+	}
+	return &link, true
+}
+
+// Save method inserts/updates link, viewer becomes owner of new link. Tags
+// are replaced by link.Tags unless it is nil. Link with the same canonical url
+// as another active link visible for viewer fails with DuplicateLinkError,
+// unless duplicates are allowed.
+func (l *memoryLinks) Save(link model.Link) (*model.Link, error) {
+	tags, err := normalizeTags(link.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if link.ID > 0 {
+		if err := l.checkWrite(link.ID); err != nil {
+			return nil, err
+		}
+	}
+	l.db.mu.Lock()
+	categoryID := 0
+	if link.Category != nil {
+		categoryID = link.Category.ID
+	}
+	if _, ok := l.db.categories[categoryID]; !ok {
+		l.db.mu.Unlock()
+		return nil, fmt.Errorf("Category with id=%d does not exist", categoryID)
+	}
+	hash := ""
+	if !l.allowDuplicate {
+		hash = canonicalHash(link.Link)
+		if existingID, ok := l.duplicateOf(hash, link.ID); ok {
+			l.db.mu.Unlock()
+			return nil, &DuplicateLinkError{ExistingID: existingID}
+		}
+	}
+	if link.ID > 0 {
+		if stored, ok := l.db.links[link.ID]; ok {
+			stored.Link.Link = link.Link
+			stored.Name = link.Name
+			stored.categoryID = categoryID
+			stored.canonicalHash = hash
+			l.db.links[link.ID] = stored
+		}
+	} else {
+		link.ID = l.db.nextID("link")
+		l.db.links[link.ID] = memoryLink{
+			Link:          model.Link{ID: link.ID, Link: link.Link, Name: link.Name, Created: createdNow()},
+			categoryID:    categoryID,
+			canonicalHash: hash,
+		}
+		if l.viewer != nil {
+			l.db.userLinks = append(l.db.userLinks,
+				memoryUserLink{userID: l.viewer.ID, linkID: link.ID, owner: true})
+		}
+	}
+	if _, ok := l.db.links[link.ID]; ok && tags != nil {
+		l.db.setLinkTags(link.ID, tags)
+	}
+	l.db.mu.Unlock()
+	return l.withInactive().Get(link.ID)
+}
+
+// duplicateOf returns the lowest id of active link visible for viewer other
+// than link by id with canonical url hash, caller must hold lock.
+func (l *memoryLinks) duplicateOf(hash string, id int) (int, bool) {
+	existingID := 0
+	for otherID, other := range l.db.links {
+		if other.canonicalHash != hash || otherID == id || (existingID > 0 && otherID > existingID) {
+			continue
+		}
+		link, ok := l.db.joinLink(other)
+		if ok && l.visible(otherID) && link.Active == nil && link.Category.Active == nil {
+			existingID = otherID
+		}
+	}
+	return existingID, existingID > 0
+}
+
+// Patch method changes fields of link by id set in patch.
+func (l *memoryLinks) Patch(id int, patch LinkPatch) (*model.Link, error) {
+	tags, err := normalizeTags(patch.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.checkWrite(id); err != nil {
+		return nil, err
+	}
+	l.db.mu.Lock()
+	stored, ok := l.db.links[id]
+	if !ok {
+		l.db.mu.Unlock()
+		return nil, sql.ErrNoRows
+	}
+	if patch.CategoryID != nil {
+		if _, ok := l.db.categories[*patch.CategoryID]; !ok {
+			l.db.mu.Unlock()
+			return nil, fmt.Errorf("Category with id=%d does not exist", *patch.CategoryID)
+		}
+		stored.categoryID = *patch.CategoryID
+	}
+	if patch.Link != nil {
+		stored.canonicalHash = ""
+		if !l.allowDuplicate {
+			stored.canonicalHash = canonicalHash(*patch.Link)
+			if existingID, ok := l.duplicateOf(stored.canonicalHash, id); ok {
+				l.db.mu.Unlock()
+				return nil, &DuplicateLinkError{ExistingID: existingID}
+			}
+		}
+		stored.Link.Link = *patch.Link
+	}
+	if patch.Name != nil {
+		stored.Name = *patch.Name
+	}
+	l.db.links[id] = stored
+	if tags != nil {
+		l.db.setLinkTags(id, tags)
+	}
+	l.db.mu.Unlock()
+	return l.withInactive().Get(id)
+}
+
+// checkWrite returns ErrForbidden when viewer is not allowed to change link by id.
+func (l *memoryLinks) checkWrite(id int) error {
+	allowed, err := l.CanWrite(id)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Delete method archives link by id.
+func (l *memoryLinks) Delete(id int, time time.Time) (*model.Link, error) {
+	if err := l.checkWrite(id); err != nil {
+		return nil, err
+	}
+	l.db.mu.Lock()
+	if stored, ok := l.db.links[id]; ok {
+		stored.Active = &time
+		l.db.links[id] = stored
+	}
+	l.db.mu.Unlock()
+	return l.withInactive().Get(id)
+}
+
+// Restore method activates archived link by id.
+func (l *memoryLinks) Restore(id int) (*model.Link, error) {
+	if err := l.checkWrite(id); err != nil {
+		return nil, err
+	}
+	l.db.mu.Lock()
+	if stored, ok := l.db.links[id]; ok {
+		stored.Active = nil
+		l.db.links[id] = stored
+	}
+	l.db.mu.Unlock()
+	return l.withInactive().Get(id)
+}
+
+// Retrieve method returns visible links matching filters, sort and page
+// filters order and limit them like in sql implementation.
+func (l *memoryLinks) Retrieve(filters map[string][]string) ([]*model.Link, error) {
+	filters, sortValues := popFilter(filters, "sort")
+	filters, page, err := popPage(filters)
+	if err != nil {
+		return nil, err
+	}
+	order, err := parseSort(sortValues, l.columns.sortFields)
+	if err != nil {
+		return nil, err
+	}
+	descending := false
+	if page.After > 0 {
+		if descending, err = cursorDescending(order, "l_id"); err != nil {
+			return nil, err
+		}
+	}
+	l.db.mu.RLock()
+	defer l.db.mu.RUnlock()
+	result, err := l.matching(filters)
+	if err != nil {
+		return nil, err
+	}
+	order = withTiebreaker(order, "l_id")
+	sort.Slice(result, func(i, j int) bool {
+		for _, field := range order {
+			compared := compareValues(linkSortValue(result[i], field.field),
+				linkSortValue(result[j], field.field))
+			if compared == 0 {
+				continue
+			}
+			return (compared < 0) != field.descending
+		}
+		return false
+	})
+	if page.After > 0 {
+		afterCursor := result[:0]
+		for _, link := range result {
+			if (link.ID > page.After && !descending) || (link.ID < page.After && descending) {
+				afterCursor = append(afterCursor, link)
+			}
+		}
+		result = afterCursor
+	}
+	if page.Offset >= len(result) {
+		return nil, nil
+	}
+	result = result[page.Offset:]
+	if page.Limit > 0 && page.Limit < len(result) {
+		result = result[:page.Limit]
+	}
+	return result, nil
+}
+
+// Count method returns number of visible links matching filters, sort and
+// page filters are ignored.
+func (l *memoryLinks) Count(filters map[string][]string) (int, error) {
+	filters, _ = popFilter(filters, "sort")
+	filters, _, err := popPage(filters)
+	if err != nil {
+		return 0, err
+	}
+	l.db.mu.RLock()
+	defer l.db.mu.RUnlock()
+	result, err := l.matching(filters)
+	if err != nil {
+		return 0, err
+	}
+	return len(result), nil
+}
+
+// matching returns visible links matching filters, caller must hold lock.
+func (l *memoryLinks) matching(filters map[string][]string) ([]*model.Link, error) {
+	filters, tags, matchAll, err := popTagFilter(filters)
+	if err != nil {
+		return nil, err
+	}
+	typedFilters, err := convertFilters(filters, l.columns.fieldsForSelect, l.columns.convertValue)
+	if err != nil {
+		return nil, err
+	}
+	var result []*model.Link
+	for _, stored := range l.db.links {
+		link, ok := l.db.joinLink(stored)
+		if !ok || !l.active(link) || !l.visible(link.ID) {
+			continue
+		}
+		if typedFilters.match(func(field string) interface{} { return linkValue(link, field) }) &&
+			matchTags(link.Tags, tags, matchAll) {
+			result = append(result, link)
+		}
+	}
+	return result, nil
+}
+
+// matchTags checks if link with linkTags has any (or all when matchAll) of tags.
+func matchTags(linkTags []string, tags []string, matchAll bool) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	found := 0
+	for _, tag := range tags {
+		if isFieldAllowed(linkTags, tag) {
+			found++
+		}
+	}
+	if matchAll {
+		return found == len(tags)
+	}
+	return found > 0
+}
+
+// linkValue returns value of link filter field.
+func linkValue(link *model.Link, field string) interface{} {
+	switch field {
+	case "l_id":
+		return link.ID
+	case "link":
+		return link.Link
+	case "l_name":
+		return link.Name
+	case "l_active":
+		return link.Active
+	case "l_created":
+		return link.Created
+	}
+	return categoryValue(link.Category, field)
+}
+
+// linkSortValue returns value of link sort field.
+func linkSortValue(link *model.Link, field string) interface{} {
+	switch field {
+	case "rating":
+		return link.Rating
+	case "rating_count":
+		return link.RatingCount
+	}
+	return linkValue(link, field)
+}
+
+// Search method returns visible links matching any word of search query
+// ordered by score, words are matched like in sql implementation without
+// FULLTEXT.
+func (l *memoryLinks) Search(query string, page Page) ([]*model.SearchResult, int, error) {
+	terms, err := searchTerms(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	l.db.mu.RLock()
+	defer l.db.mu.RUnlock()
+	links, err := l.matching(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	var result []*model.SearchResult
+	for _, link := range links {
+		notes := l.db.publicNotes(link.ID)
+		score := 0
+		for _, term := range terms {
+			score += 2*containsTerm(link.Name, term) + containsTerm(link.Link, term) +
+				containsTerm(link.Category.Name, term)
+			for _, note := range notes {
+				score += containsTerm(note, term)
+			}
+		}
+		if score > 0 {
+			result = append(result, &model.SearchResult{Link: link, Score: float64(score),
+				Snippets: searchSnippets(link, notes, terms)})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Link.ID < result[j].Link.ID
+	})
+	total := len(result)
+	if page.Offset >= len(result) {
+		return nil, total, nil
+	}
+	result = result[page.Offset:]
+	if page.Limit > 0 && page.Limit < len(result) {
+		result = result[:page.Limit]
+	}
+	return result, total, nil
+}
+
+// publicNotes returns texts of public notes of link ordered by id, caller must hold lock.
+func (m *memoryDB) publicNotes(linkID int) []string {
+	var linkNotes []memoryNote
+	for _, note := range m.notes {
+		if note.linkID == linkID && !note.private {
+			linkNotes = append(linkNotes, note)
+		}
+	}
+	sort.Slice(linkNotes, func(i, j int) bool { return linkNotes[i].id < linkNotes[j].id })
+	notes := make([]string, len(linkNotes))
+	for i, note := range linkNotes {
+		notes[i] = note.note
+	}
+	return notes
+}
+
+// containsTerm returns 1 when text contains lower case term ignoring case, otherwise 0.
+func containsTerm(text string, term string) int {
+	if strings.Contains(strings.ToLower(text), term) {
+		return 1
+	}
+	return 0
+}
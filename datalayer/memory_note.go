@@ -0,0 +1,84 @@
+package datalayer
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryNotes type is in-memory implementation of NoteStore.
+type memoryNotes struct {
+	db *memoryDB
+}
+
+// Get method returns note by id.
+func (n *memoryNotes) Get(id int) (*model.Note, error) {
+	n.db.mu.RLock()
+	defer n.db.mu.RUnlock()
+	note, ok := n.db.notes[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return n.db.joinNote(note)
+}
+
+// joinNote returns note with its author, caller must hold lock.
+func (m *memoryDB) joinNote(note memoryNote) (*model.Note, error) {
+	user, ok := m.users[note.userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	created := note.created
+	return &model.Note{ID: note.id, User: &model.User{ID: user.ID, Name: user.Name},
+		Link: &model.Link{ID: note.linkID}, Note: note.note, Private: note.private,
+		Created: &created}, nil
+}
+
+// ForLink method returns notes of link visible for user: public notes
+// and private notes of the user.
+func (n *memoryNotes) ForLink(linkID int, userID int) ([]*model.Note, error) {
+	n.db.mu.RLock()
+	defer n.db.mu.RUnlock()
+	result := []*model.Note{}
+	for _, stored := range n.db.notes {
+		if stored.linkID != linkID || (stored.private && stored.userID != userID) {
+			continue
+		}
+		note, err := n.db.joinNote(stored)
+		if err != nil {
+			continue
+		}
+		result = append(result, note)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// Save method inserts/updates note, author and link of existing note are
+// never changed.
+func (n *memoryNotes) Save(note model.Note) (*model.Note, error) {
+	n.db.mu.Lock()
+	id := note.ID
+	if id > 0 {
+		if stored, ok := n.db.notes[id]; ok {
+			stored.note = note.Note
+			stored.private = note.Private
+			n.db.notes[id] = stored
+		}
+	} else {
+		id = n.db.nextID("note")
+		n.db.notes[id] = memoryNote{id: id, userID: note.User.ID, linkID: note.Link.ID,
+			note: note.Note, private: note.Private, created: *createdNow()}
+	}
+	n.db.mu.Unlock()
+	return n.Get(id)
+}
+
+// Delete method removes note by id.
+func (n *memoryNotes) Delete(id int) error {
+	n.db.mu.Lock()
+	defer n.db.mu.Unlock()
+	delete(n.db.notes, id)
+	return nil
+}
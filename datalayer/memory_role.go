@@ -0,0 +1,111 @@
+package datalayer
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryRoles type is in-memory implementation of RoleStore.
+type memoryRoles struct {
+	db      *memoryDB
+	columns *Roles
+}
+
+// Get method returns role by id together with its active users and links
+// shared with the role.
+func (r *memoryRoles) Get(id int) (*model.Role, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	role, ok := r.db.roles[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	users := []model.UserRole{}
+	for _, userRole := range r.db.userRoles {
+		stored, ok := r.db.users[userRole.userID]
+		if userRole.roleID != id || !ok || stored.Active != nil {
+			continue
+		}
+		user := stored.User
+		users = append(users, model.UserRole{ID: userRole.id, User: &user})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	links := []model.RoleLink{}
+	for _, roleLink := range r.db.roleLinks {
+		stored, ok := r.db.links[roleLink.linkID]
+		if roleLink.roleID != id || !ok {
+			continue
+		}
+		category, ok := r.db.categories[stored.categoryID]
+		if !ok {
+			continue
+		}
+		link := stored.Link
+		link.Category = &category
+		links = append(links, model.RoleLink{ID: roleLink.id, Link: &link})
+	}
+	role.Users = &users
+	role.Links = &links
+	return &role, nil
+}
+
+// Save method inserts/updates role, users and links of role are not saved.
+func (r *memoryRoles) Save(role model.Role) (*model.Role, error) {
+	r.db.mu.Lock()
+	stored, ok := r.db.roles[role.ID]
+	if !ok {
+		stored = model.Role{ID: r.db.nextID("role"), Created: createdNow()}
+	}
+	stored.Name = role.Name
+	r.db.roles[stored.ID] = stored
+	r.db.mu.Unlock()
+	return r.Get(stored.ID)
+}
+
+// Delete method archives role by id.
+func (r *memoryRoles) Delete(id int, time time.Time) (*model.Role, error) {
+	r.db.mu.Lock()
+	if stored, ok := r.db.roles[id]; ok {
+		stored.Active = &time
+		r.db.roles[id] = stored
+	}
+	r.db.mu.Unlock()
+	return r.Get(id)
+}
+
+// Retrieve method returns roles matching filters, users and links are not loaded.
+func (r *memoryRoles) Retrieve(filters map[string][]string) ([]*model.Role, error) {
+	typedFilters, err := convertFilters(filters, r.columns.fieldsForSelect, r.columns.convertValue)
+	if err != nil {
+		return nil, err
+	}
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var result []*model.Role
+	for _, role := range r.db.roles {
+		role := role
+		if typedFilters.match(func(field string) interface{} { return roleValue(&role, field) }) {
+			result = append(result, &role)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// roleValue returns value of role filter field.
+func roleValue(role *model.Role, field string) interface{} {
+	switch field {
+	case "r_id":
+		return role.ID
+	case "r_name":
+		return role.Name
+	case "r_active":
+		return role.Active
+	case "r_created":
+		return role.Created
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package datalayer
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// memorySessions type is in-memory implementation of SessionStore.
+type memorySessions struct {
+	db *memoryDB
+}
+
+// Create method creates new session of user valid until expires and returns its token.
+func (s *memorySessions) Create(userID int, expires time.Time) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.sessions[hashToken(token)] = memorySession{userID: userID, expires: expires}
+	return token, nil
+}
+
+// User method returns active user of valid session by session token.
+func (s *memorySessions) User(token string, now time.Time) (*model.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	session, ok := s.db.sessions[hashToken(token)]
+	if !ok || !session.expires.After(now) {
+		return nil, sql.ErrNoRows
+	}
+	stored, ok := s.db.users[session.userID]
+	if !ok || stored.Active != nil {
+		return nil, sql.ErrNoRows
+	}
+	user := stored.User
+	return &user, nil
+}
+
+// Delete method removes session by session token.
+func (s *memorySessions) Delete(token string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	delete(s.db.sessions, hashToken(token))
+	return nil
+}
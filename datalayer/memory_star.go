@@ -0,0 +1,62 @@
+package datalayer
+
+import (
+	"database/sql"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryStars type is in-memory implementation of StarStore.
+type memoryStars struct {
+	db *memoryDB
+}
+
+// Get method returns rating of link by user.
+func (s *memoryStars) Get(userID int, linkID int) (*model.Star, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	star, ok := s.db.findStar(userID, linkID)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	created := star.created
+	return &model.Star{ID: star.id, User: &model.User{ID: star.userID},
+		Link: &model.Link{ID: star.linkID}, Stars: star.stars, Created: &created}, nil
+}
+
+// findStar returns rating of link by user, caller must hold lock.
+func (m *memoryDB) findStar(userID int, linkID int) (memoryStar, bool) {
+	for _, star := range m.stars {
+		if star.userID == userID && star.linkID == linkID {
+			return star, true
+		}
+	}
+	return memoryStar{}, false
+}
+
+// Set method sets or changes rating of link by user.
+func (s *memoryStars) Set(userID int, linkID int, stars int) (*model.Star, error) {
+	if stars < MinStars || stars > MaxStars {
+		return nil, ErrInvalidStars
+	}
+	s.db.mu.Lock()
+	star, ok := s.db.findStar(userID, linkID)
+	if !ok {
+		star = memoryStar{id: s.db.nextID("star"), userID: userID, linkID: linkID,
+			created: *createdNow()}
+	}
+	star.stars = stars
+	s.db.stars[star.id] = star
+	s.db.mu.Unlock()
+	return s.Get(userID, linkID)
+}
+
+// Delete method removes rating of link by user.
+func (s *memoryStars) Delete(userID int, linkID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if star, ok := s.db.findStar(userID, linkID); ok {
+		delete(s.db.stars, star.id)
+	}
+	return nil
+}
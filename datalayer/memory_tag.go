@@ -0,0 +1,165 @@
+package datalayer
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryTags type is in-memory implementation of TagStore.
+type memoryTags struct {
+	db     *memoryDB
+	viewer *model.User
+}
+
+// ForUser method returns tags which count only links visible for user.
+func (t *memoryTags) ForUser(user *model.User) TagStore {
+	scoped := *t
+	scoped.viewer = user
+	return &scoped
+}
+
+// Get method returns tag by id with number of its links.
+func (t *memoryTags) Get(id int) (*model.Tag, error) {
+	t.db.mu.RLock()
+	defer t.db.mu.RUnlock()
+	tag, ok := t.db.tags[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	tag.LinkCount = t.linkCount(id)
+	return &tag, nil
+}
+
+// Retrieve method returns all tags with numbers of their links, most used first.
+func (t *memoryTags) Retrieve() ([]*model.Tag, error) {
+	t.db.mu.RLock()
+	defer t.db.mu.RUnlock()
+	result := []*model.Tag{}
+	for _, tag := range t.db.tags {
+		tag := tag
+		tag.LinkCount = t.linkCount(tag.ID)
+		result = append(result, &tag)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LinkCount != result[j].LinkCount {
+			return result[i].LinkCount > result[j].LinkCount
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// Rename method changes name of tag by id, name of another tag fails with ErrDuplicateTag.
+func (t *memoryTags) Rename(id int, name string) (*model.Tag, error) {
+	name, err := normalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
+	t.db.mu.Lock()
+	tag, ok := t.db.tags[id]
+	if !ok {
+		t.db.mu.Unlock()
+		return nil, sql.ErrNoRows
+	}
+	if existingID, ok := t.db.tagID(name); ok && existingID != id {
+		t.db.mu.Unlock()
+		return nil, ErrDuplicateTag
+	}
+	tag.Name = name
+	t.db.tags[id] = tag
+	t.db.mu.Unlock()
+	return t.Get(id)
+}
+
+// Merge method moves links of source tag to target tag and deletes source tag.
+func (t *memoryTags) Merge(sourceID int, targetID int) (*model.Tag, error) {
+	t.db.mu.Lock()
+	_, sourceOk := t.db.tags[sourceID]
+	_, targetOk := t.db.tags[targetID]
+	if !sourceOk || !targetOk {
+		t.db.mu.Unlock()
+		return nil, sql.ErrNoRows
+	}
+	if sourceID != targetID {
+		targetLinks := make(map[int]bool)
+		for _, linkTag := range t.db.linkTags {
+			if linkTag.tagID == targetID {
+				targetLinks[linkTag.linkID] = true
+			}
+		}
+		var linkTags []memoryLinkTag
+		for _, linkTag := range t.db.linkTags {
+			if linkTag.tagID == sourceID {
+				if targetLinks[linkTag.linkID] {
+					continue
+				}
+				linkTag.tagID = targetID
+			}
+			linkTags = append(linkTags, linkTag)
+		}
+		t.db.linkTags = linkTags
+		delete(t.db.tags, sourceID)
+	}
+	t.db.mu.Unlock()
+	return t.Get(targetID)
+}
+
+// linkCount returns number of active links with tag visible for viewer,
+// caller must hold lock.
+func (t *memoryTags) linkCount(tagID int) int {
+	links := &memoryLinks{db: t.db, viewer: t.viewer}
+	count := 0
+	for _, linkTag := range t.db.linkTags {
+		if linkTag.tagID != tagID || !links.visible(linkTag.linkID) {
+			continue
+		}
+		if link, ok := t.db.joinLink(t.db.links[linkTag.linkID]); ok && links.active(link) {
+			count++
+		}
+	}
+	return count
+}
+
+// tagID returns id of tag by name, caller must hold lock.
+func (m *memoryDB) tagID(name string) (int, bool) {
+	for _, tag := range m.tags {
+		if tag.Name == name {
+			return tag.ID, true
+		}
+	}
+	return 0, false
+}
+
+// setLinkTags replaces tags of link by tags with names, missing tags are
+// created, caller must hold write lock.
+func (m *memoryDB) setLinkTags(linkID int, names []string) {
+	var linkTags []memoryLinkTag
+	for _, linkTag := range m.linkTags {
+		if linkTag.linkID != linkID {
+			linkTags = append(linkTags, linkTag)
+		}
+	}
+	for _, name := range names {
+		tagID, ok := m.tagID(name)
+		if !ok {
+			tagID = m.nextID("tag")
+			m.tags[tagID] = model.Tag{ID: tagID, Name: name, Created: createdNow()}
+		}
+		linkTags = append(linkTags, memoryLinkTag{linkID: linkID, tagID: tagID})
+	}
+	m.linkTags = linkTags
+}
+
+// linkTagNames returns sorted names of tags of link, caller must hold lock.
+func (m *memoryDB) linkTagNames(linkID int) []string {
+	var names []string
+	for _, linkTag := range m.linkTags {
+		if linkTag.linkID == linkID {
+			names = append(names, m.tags[linkTag.tagID].Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
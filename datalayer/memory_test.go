@@ -0,0 +1,66 @@
+package datalayer
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/chytilp/links/model"
+)
+
+func TestMemoryStoreShouldBeSafeForConcurrentUse(t *testing.T) {
+	store := CreateMemoryStore()
+	user := &model.User{ID: 1}
+	category, err := store.Categories.Save(model.Category{Name: "golang"})
+	if err != nil {
+		t.Fatalf("Categories.Save should save category, but error: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link, err := store.Links.ForUser(user).AllowDuplicate().Save(model.Link{
+				Link: "https://golang.org", Name: "Go", Category: category})
+			if err != nil {
+				t.Errorf("Links.Save should save link, but error: %v", err)
+				return
+			}
+			if _, err := store.Stars.Set(user.ID, link.ID, 5); err != nil {
+				t.Errorf("Stars.Set should save rating, but error: %v", err)
+			}
+			if _, err := store.Links.ForUser(user).Retrieve(nil); err != nil {
+				t.Errorf("Links.Retrieve should return links, but error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	links, err := store.Links.ForUser(user).Retrieve(nil)
+	if err != nil || len(links) != 20 {
+		t.Errorf("Links.Retrieve should return 20 links, but returns: %d, %v", len(links), err)
+	}
+}
+
+func TestMemoryRetrieveShouldFilterAndSortLikeSQL(t *testing.T) {
+	store := CreateMemoryStore()
+	category, _ := store.Categories.Save(model.Category{Name: "golang"})
+	other, _ := store.Categories.Save(model.Category{Name: "rust"})
+	first, _ := store.Links.Save(model.Link{Link: "https://golang.org", Name: "Go", Category: category})
+	second, _ := store.Links.Save(model.Link{Link: "https://go.dev", Name: "Go dev", Category: category})
+	store.Links.Save(model.Link{Link: "https://rust-lang.org", Name: "Rust", Category: other})
+	store.Stars.Set(1, second.ID, 5)
+	store.Stars.Set(1, first.ID, 2)
+
+	links, err := store.Links.Retrieve(map[string][]string{"c_id": {"1"}, "sort": {"-rating"}})
+	if err != nil {
+		t.Fatalf("Links.Retrieve should return links, but error: %v", err)
+	}
+	if len(links) != 2 || links[0].ID != second.ID || links[1].ID != first.ID {
+		t.Errorf("Links.Retrieve should return links of category by rating, but returns: %v", links)
+	}
+	if _, err := store.Links.Retrieve(map[string][]string{"password": {"x"}}); err == nil {
+		t.Errorf("Links.Retrieve should reject unknown field")
+	}
+	if _, err := store.Links.Retrieve(map[string][]string{"sort": {"name"}}); err == nil {
+		t.Errorf("Links.Retrieve should reject unknown sort field")
+	}
+}
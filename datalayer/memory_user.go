@@ -0,0 +1,190 @@
+package datalayer
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryUsers type is in-memory implementation of UserStore.
+type memoryUsers struct {
+	db      *memoryDB
+	columns *Users
+}
+
+// Get method returns user by id together with its roles.
+func (u *memoryUsers) Get(id int) (*model.User, error) {
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	stored, ok := u.db.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	user := stored.User
+	roles := u.db.rolesOfUser(id)
+	user.Roles = &roles
+	return &user, nil
+}
+
+// rolesOfUser returns roles assigned to user, caller must hold lock.
+func (m *memoryDB) rolesOfUser(userID int) []model.UserRole {
+	roles := []model.UserRole{}
+	for _, userRole := range m.userRoles {
+		if userRole.userID != userID {
+			continue
+		}
+		role, ok := m.roles[userRole.roleID]
+		if !ok {
+			continue
+		}
+		role.Users, role.Links = nil, nil
+		roles = append(roles, model.UserRole{ID: userRole.id, Role: &role})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].ID < roles[j].ID })
+	return roles
+}
+
+// FindByEmail method returns user by email, without roles. Emails are
+// compared case-insensitively.
+func (u *memoryUsers) FindByEmail(email string) (*model.User, error) {
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	for _, stored := range u.db.users {
+		if strings.EqualFold(stored.Email, email) {
+			user := stored.User
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// Save method inserts/updates user, password is stored as bcrypt hash and
+// roles are replaced when user.Roles is not nil. Email of another user fails
+// with ErrDuplicateEmail.
+func (u *memoryUsers) Save(user model.User) (*model.User, error) {
+	if user.ID == 0 && user.Password == "" {
+		return nil, ErrPasswordRequired
+	}
+	roleIDs, err := roleIDs(user.Roles)
+	if err != nil {
+		return nil, err
+	}
+	var passwordHash string
+	if user.Password != "" {
+		passwordHash, err = hashPassword(user.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+	u.db.mu.Lock()
+	for id, stored := range u.db.users {
+		if strings.EqualFold(stored.Email, user.Email) && id != user.ID {
+			u.db.mu.Unlock()
+			return nil, ErrDuplicateEmail
+		}
+	}
+	id := user.ID
+	stored, ok := u.db.users[id]
+	if !ok {
+		id = u.db.nextID("user")
+		stored = memoryUser{User: model.User{ID: id, Created: createdNow()}}
+	}
+	stored.Name = user.Name
+	stored.Email = user.Email
+	stored.Superadmin = user.Superadmin
+	if passwordHash != "" {
+		stored.password = passwordHash
+	}
+	u.db.users[id] = stored
+	if user.Roles != nil {
+		for userRoleID, userRole := range u.db.userRoles {
+			if userRole.userID == id {
+				delete(u.db.userRoles, userRoleID)
+			}
+		}
+		for _, roleID := range roleIDs {
+			userRoleID := u.db.nextID("user_role")
+			u.db.userRoles[userRoleID] = memoryUserRole{id: userRoleID, userID: id, roleID: roleID}
+		}
+	}
+	u.db.mu.Unlock()
+	return u.Get(id)
+}
+
+// Delete method archives user by id.
+func (u *memoryUsers) Delete(id int, time time.Time) (*model.User, error) {
+	u.db.mu.Lock()
+	if stored, ok := u.db.users[id]; ok {
+		stored.Active = &time
+		u.db.users[id] = stored
+	}
+	u.db.mu.Unlock()
+	return u.Get(id)
+}
+
+// Retrieve method returns users matching filters, roles are not loaded.
+func (u *memoryUsers) Retrieve(filters map[string][]string) ([]*model.User, error) {
+	typedFilters, err := convertFilters(filters, u.columns.fieldsForSelect, u.columns.convertValue)
+	if err != nil {
+		return nil, err
+	}
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	var result []*model.User
+	for _, stored := range u.db.users {
+		user := stored.User
+		if typedFilters.match(func(field string) interface{} { return userValue(&user, field) }) {
+			result = append(result, &user)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// userValue returns value of user filter field.
+func userValue(user *model.User, field string) interface{} {
+	switch field {
+	case "u_id":
+		return user.ID
+	case "u_name":
+		return user.Name
+	case "email":
+		return user.Email
+	case "superadmin":
+		return user.Superadmin
+	case "u_active":
+		return user.Active
+	case "u_created":
+		return user.Created
+	}
+	return nil
+}
+
+// VerifyPassword method checks password of user by id.
+func (u *memoryUsers) VerifyPassword(id int, password string) (bool, error) {
+	u.db.mu.RLock()
+	stored, ok := u.db.users[id]
+	u.db.mu.RUnlock()
+	if !ok {
+		return false, sql.ErrNoRows
+	}
+	ok, rehash, err := verifyPassword(stored.password, password)
+	if err != nil || !ok {
+		return false, err
+	}
+	if rehash {
+		passwordHash, err := hashPassword(password)
+		if err != nil {
+			return false, err
+		}
+		u.db.mu.Lock()
+		stored = u.db.users[id]
+		stored.password = passwordHash
+		u.db.users[id] = stored
+		u.db.mu.Unlock()
+	}
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package datalayer
+
+import (
+	"database/sql"
+
+	"github.com/chytilp/links/model"
+)
+
+// memoryUserRoles type is in-memory implementation of UserRoleStore.
+type memoryUserRoles struct {
+	db *memoryDB
+}
+
+// Get method returns user_role by id with its user and role.
+func (u *memoryUserRoles) Get(id int) (*model.UserRole, error) {
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	userRole, ok := u.db.userRoles[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return u.db.joinUserRole(userRole)
+}
+
+// Find method returns user_role by user id and role id.
+func (u *memoryUserRoles) Find(userID int, roleID int) (*model.UserRole, error) {
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	for _, userRole := range u.db.userRoles {
+		if userRole.userID == userID && userRole.roleID == roleID {
+			return u.db.joinUserRole(userRole)
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// joinUserRole returns user_role with its user and role, caller must hold lock.
+func (m *memoryDB) joinUserRole(userRole memoryUserRole) (*model.UserRole, error) {
+	stored, userOk := m.users[userRole.userID]
+	role, roleOk := m.roles[userRole.roleID]
+	if !userOk || !roleOk {
+		return nil, sql.ErrNoRows
+	}
+	user := stored.User
+	return &model.UserRole{ID: userRole.id, User: &user, Role: &role}, nil
+}
+
+// Save method inserts/updates user_role, assigning role which user already
+// has in another record fails with ErrDuplicateUserRole.
+func (u *memoryUserRoles) Save(userRole model.UserRole) (*model.UserRole, error) {
+	existing, err := u.Find(userRole.User.ID, userRole.Role.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && existing.ID != userRole.ID {
+		return nil, ErrDuplicateUserRole
+	}
+	u.db.mu.Lock()
+	id := userRole.ID
+	if _, ok := u.db.userRoles[id]; ok || id == 0 {
+		if id == 0 {
+			id = u.db.nextID("user_role")
+		}
+		u.db.userRoles[id] = memoryUserRole{id: id, userID: userRole.User.ID, roleID: userRole.Role.ID}
+	}
+	u.db.mu.Unlock()
+	return u.Get(id)
+}
+
+// Delete method removes user_role by id.
+func (u *memoryUserRoles) Delete(id int) error {
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	delete(u.db.userRoles, id)
+	return nil
+}
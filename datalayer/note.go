@@ -0,0 +1,100 @@
+package datalayer
+
+import (
+	"database/sql"
+
+	"github.com/chytilp/links/model"
+)
+
+// Notes type wrapps database methods above note table.
+type Notes struct {
+	records       *records
+	selectPattern string
+	insertPattern string
+	updatePattern string
+	deletePattern string
+}
+
+// CreateNotes creates and returns instance of Notes struct.
+func CreateNotes(db *sql.DB) *Notes {
+	notes := &Notes{
+		records: newRecords(db),
+		selectPattern: "SELECT n.id AS n_id, n.link_id, n.note, n.private, n.created AS n_created, " +
+			"u.id AS u_id, u.name AS u_name " +
+			"FROM note n " +
+			"JOIN `user` u on n.user_id = u.id ",
+		insertPattern: "INSERT INTO note(user_id, link_id, note, private) VALUES(?, ?, ?, ?)",
+		updatePattern: "UPDATE note SET note=?, private=? WHERE id=?",
+		deletePattern: "DELETE FROM note WHERE id=?",
+	}
+	return notes
+}
+
+// Get method returns note record from note table by id.
+func (n *Notes) Get(id int) (*model.Note, error) {
+	row := n.records.db.QueryRow(n.selectPattern+" WHERE n.id = ?", id)
+	note, err := n.scanRow(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// ForLink method returns notes of link visible for user: public notes
+// and private notes of the user.
+func (n *Notes) ForLink(linkID int, userID int) ([]*model.Note, error) {
+	query := n.selectPattern + " WHERE n.link_id = ? AND (n.private = ? OR n.user_id = ?) ORDER BY n.id"
+	rows, err := n.records.db.Query(query, linkID, false, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []*model.Note{}
+	for rows.Next() {
+		note, err := n.scanRow(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, note)
+	}
+	return result, rows.Err()
+}
+
+// Save method insert/update record in note table. Author and link
+// of existing note are never changed.
+func (n *Notes) Save(note model.Note) (*model.Note, error) {
+	var id int
+	var err error
+	if note.ID > 0 {
+		id = note.ID
+		err = n.records.update([]interface{}{note.Note, note.Private, note.ID}, n.updatePattern)
+	} else {
+		values := []interface{}{
+			note.User.ID,
+			note.Link.ID,
+			note.Note,
+			note.Private,
+		}
+		id, err = n.records.insert(values, n.insertPattern)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n.Get(id)
+}
+
+// Delete method removes record from note table by id.
+func (n *Notes) Delete(id int) error {
+	return n.records.update([]interface{}{id}, n.deletePattern)
+}
+
+// scanRow fills note structure with values from db record.
+func (n *Notes) scanRow(fn scanner) (*model.Note, error) {
+	note := &model.Note{User: &model.User{}, Link: &model.Link{}}
+	err := fn(&note.ID, &note.Link.ID, &note.Note, &note.Private, &note.Created,
+		&note.User.ID, &note.User.Name)
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
@@ -0,0 +1,121 @@
+package datalayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chytilp/links/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func createNote(id int, userID int, linkID int, private bool) *model.Note {
+	created := time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC)
+	return &model.Note{
+		ID:      id,
+		User:    &model.User{ID: userID, Name: "petr"},
+		Link:    &model.Link{ID: linkID},
+		Note:    "note 1",
+		Private: private,
+		Created: &created,
+	}
+}
+
+func createMockNoteRows(notes ...*model.Note) *sqlmock.Rows {
+	columns := []string{"n_id", "link_id", "note", "private", "n_created", "u_id", "u_name"}
+	rows := sqlmock.NewRows(columns)
+	for _, note := range notes {
+		rows.AddRow(note.ID, note.Link.ID, note.Note, note.Private, note.Created,
+			note.User.ID, note.User.Name)
+	}
+	return rows
+}
+
+func TestNoteForLinkShouldReturnVisibleNotes(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	expectedNotes := []*model.Note{createNote(1, 2, 3, false), createNote(2, 5, 3, true)}
+	mock.ExpectQuery("^SELECT (.+) FROM note n JOIN `user` u on n.user_id = u.id "+
+		"WHERE n.link_id = \\? AND \\(n.private = \\? OR n.user_id = \\?\\) ORDER BY n.id").
+		WithArgs(3, false, 5).
+		WillReturnRows(createMockNoteRows(expectedNotes...))
+	notes := CreateNotes(db)
+	defer db.Close()
+	outputNotes, err := notes.ForLink(3, 5)
+	if err != nil {
+		t.Errorf("Notes.ForLink should return notes, but error: %v", err)
+	}
+	same := cmp.Equal(outputNotes, expectedNotes)
+	if !same {
+		t.Errorf("Note object are different: %#v, %#v", outputNotes, expectedNotes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestNoteSaveShouldInsertRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	note := createNote(0, 2, 3, true)
+	expectedNote := createNote(1, 2, 3, true)
+	mock.ExpectPrepare("^INSERT INTO note\\(user_id, link_id, note, private\\) VALUES\\(\\?, \\?, \\?, \\?\\)").
+		ExpectExec().
+		WithArgs(2, 3, note.Note, true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT (.+) FROM note n JOIN `user` u on n.user_id = u.id WHERE n.id = \\?").
+		WithArgs(1).
+		WillReturnRows(createMockNoteRows(expectedNote))
+	notes := CreateNotes(db)
+	defer db.Close()
+	outputNote, err := notes.Save(*note)
+	if err != nil {
+		t.Errorf("Notes.Save[%#v] should insert record, but error: %v", note, err)
+	}
+	same := cmp.Equal(outputNote, expectedNote)
+	if !same {
+		t.Errorf("Note object are different: %#v, %#v", outputNote, expectedNote)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestNoteSaveShouldUpdateRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	note := createNote(1, 2, 3, false)
+	mock.ExpectPrepare("^UPDATE note SET note=\\?, private=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(note.Note, false, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^SELECT (.+) FROM note n JOIN `user` u on n.user_id = u.id WHERE n.id = \\?").
+		WithArgs(1).
+		WillReturnRows(createMockNoteRows(note))
+	notes := CreateNotes(db)
+	defer db.Close()
+	outputNote, err := notes.Save(*note)
+	if err != nil {
+		t.Errorf("Notes.Save[%#v] should update record, but error: %v", note, err)
+	}
+	same := cmp.Equal(outputNote, note)
+	if !same {
+		t.Errorf("Note object are different: %#v, %#v", outputNote, note)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestNoteDeleteShouldRemoveRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	mock.ExpectPrepare("^DELETE FROM note WHERE id=\\?").
+		ExpectExec().
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	notes := CreateNotes(db)
+	defer db.Close()
+	if err := notes.Delete(1); err != nil {
+		t.Errorf("Notes.Delete[%d] should remove record, but error: %v", 1, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1 +1,240 @@
 package datalayer
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// Roles type wrapps database methods above role table.
+type Roles struct {
+	records         *records
+	fieldsForSelect []string
+	selectPattern   string
+	insertPattern   string
+	updatePattern   string
+	deletePattern   string
+	usersPattern    string
+	linksPattern    string
+}
+
+// CreateRoles creates and returns instance of Roles struct.
+func CreateRoles(db *sql.DB) *Roles {
+	roles := &Roles{
+		records:         newRecords(db),
+		fieldsForSelect: []string{"r_id", "r_name", "r_active", "r_created"},
+		selectPattern: "SELECT r.id AS r_id, r.name AS r_name, r.active AS r_active, " +
+			"r.created AS r_created " +
+			"FROM role r ",
+		insertPattern: "INSERT INTO role(name) VALUES(?)",
+		updatePattern: "UPDATE role SET name=? WHERE id=?",
+		deletePattern: "UPDATE role SET active=? WHERE id=?",
+		usersPattern: "SELECT ur.id AS ur_id, u.id AS u_id, u.name AS u_name, u.email, " +
+			"u.superadmin, u.active AS u_active, u.created AS u_created " +
+			"FROM user_role ur " +
+			"JOIN `user` u on ur.user_id = u.id " +
+			"WHERE ur.role_id = ? AND u.active IS NULL",
+		linksPattern: "SELECT rl.id AS rl_id, l.id AS l_id, l.link, l.name AS l_name, " +
+			"l.active AS l_active, l.created AS l_created, c.id AS c_id, c.name AS c_name, " +
+			"c.parent_id, c.active AS c_active, c.created AS c_created " +
+			"FROM role_link rl " +
+			"JOIN link l on rl.link_id = l.id " +
+			"JOIN category c on l.category_id = c.id " +
+			"WHERE rl.role_id = ?",
+	}
+	return roles
+}
+
+// Get method returns role record from role table by id together with
+// its active users and links shared with the role.
+func (r *Roles) Get(id int) (*model.Role, error) {
+	row := r.records.db.QueryRow(r.selectPattern+" WHERE r.id = ?", id)
+	role, err := r.scanRow(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	users, err := r.users(role.ID)
+	if err != nil {
+		return nil, err
+	}
+	role.Users = &users
+	links, err := r.links(role.ID)
+	if err != nil {
+		return nil, err
+	}
+	role.Links = &links
+	return role, nil
+}
+
+// users returns active users which have role assigned.
+func (r *Roles) users(roleID int) ([]model.UserRole, error) {
+	rows, err := r.records.db.Query(r.usersPattern, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []model.UserRole{}
+	for rows.Next() {
+		userRole := model.UserRole{User: &model.User{}}
+		err := rows.Scan(&userRole.ID, &userRole.User.ID, &userRole.User.Name,
+			&userRole.User.Email, &userRole.User.Superadmin, &userRole.User.Active,
+			&userRole.User.Created)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, userRole)
+	}
+	return users, rows.Err()
+}
+
+// links returns links shared with role.
+func (r *Roles) links(roleID int) ([]model.RoleLink, error) {
+	rows, err := r.records.db.Query(r.linksPattern, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	links := []model.RoleLink{}
+	for rows.Next() {
+		roleLink := model.RoleLink{Link: &model.Link{Category: &model.Category{}}}
+		link := roleLink.Link
+		err := rows.Scan(&roleLink.ID, &link.ID, &link.Link, &link.Name, &link.Active,
+			&link.Created, &link.Category.ID, &link.Category.Name, &link.Category.ParentID,
+			&link.Category.Active, &link.Category.Created)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, roleLink)
+	}
+	return links, rows.Err()
+}
+
+// Save method insert/update record in role table. Users and links of role
+// are not saved.
+func (r *Roles) Save(role model.Role) (*model.Role, error) {
+	var id int
+	var err error
+	if role.ID > 0 {
+		err := r.update(role)
+		if err != nil {
+			return nil, err
+		}
+		id = role.ID
+	} else {
+		id, err = r.insert(role)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.Get(id)
+}
+
+// insert new record to role table.
+func (r *Roles) insert(role model.Role) (int, error) {
+	values := []interface{}{
+		role.Name,
+	}
+	id, err := r.records.insert(values, r.insertPattern)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// update record in role table.
+func (r *Roles) update(role model.Role) error {
+	values := []interface{}{
+		role.Name,
+		role.ID,
+	}
+	err := r.records.update(values, r.updatePattern)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete method archives record in role table by id.
+func (r *Roles) Delete(id int, time time.Time) (*model.Role, error) {
+	values := []interface{}{
+		time,
+		id,
+	}
+	err := r.records.update(values, r.deletePattern)
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(id)
+}
+
+// Retrieve method selects from role table records by sended filers.
+// Users and links of roles are not loaded.
+func (r *Roles) Retrieve(filters map[string][]string) ([]*model.Role, error) {
+	whereClause, values, err := r.buildFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	var rows *sql.Rows
+	if len(whereClause) != 0 {
+		query := r.selectPattern + "WHERE " + whereClause
+		rows, err = r.records.db.Query(query, values...)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rows, err = r.records.db.Query(r.selectPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+	var result []*model.Role
+	for rows.Next() {
+		role, err := r.scanRow(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, role)
+	}
+	return result, rows.Err()
+}
+
+// buildFilters creates sql filter and prepare values from request input.
+func (r *Roles) buildFilters(filters map[string][]string) (string, []interface{}, error) {
+	return buildFilters(filters, r.fieldsForSelect, r.convertValue)
+}
+
+// scanRow fills role structure with values from db record.
+func (r *Roles) scanRow(fn scanner) (*model.Role, error) {
+	role := &model.Role{}
+	err := fn(&role.ID, &role.Name, &role.Active, &role.Created)
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// convertValues converts value from request to correct type.
+func (r *Roles) convertValue(field string, value string) (interface{}, error) {
+	switch field {
+	case "r_id":
+		intVal, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		return intVal, nil
+	case "r_name":
+		return value, nil
+	case "r_active", "r_created":
+		// 2014-11-12T11:45:26.371Z
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("Unknown field %s", field)
+}
@@ -1 +1,189 @@
 package datalayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chytilp/links/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func createRole(id int, name string) *model.Role {
+	created := time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC)
+	expectedRole := &model.Role{
+		ID:      id,
+		Name:    name,
+		Active:  nil,
+		Created: &created,
+	}
+	return expectedRole
+}
+
+func createRoleWithMembers(id int, name string) *model.Role {
+	role := createRole(id, name)
+	user := createUser(1, "petr")
+	user.Roles = nil
+	users := []model.UserRole{{ID: 1, User: user}}
+	links := []model.RoleLink{{ID: 1, Link: createLink(1, "link 1")}}
+	role.Users = &users
+	role.Links = &links
+	return role
+}
+
+func createMockRoleGetExpectedQuery(mock sqlmock.Sqlmock, role *model.Role, id int) {
+	columns := []string{"r_id", "r_name", "r_active", "r_created"}
+	mock.ExpectQuery("^SELECT (.+) FROM role r WHERE r.id = ?").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			role.ID, role.Name, role.Active, role.Created))
+	userColumns := []string{"ur_id", "u_id", "u_name", "email", "superadmin", "u_active",
+		"u_created"}
+	userRows := sqlmock.NewRows(userColumns)
+	for _, userRole := range *role.Users {
+		user := userRole.User
+		userRows.AddRow(userRole.ID, user.ID, user.Name, user.Email, user.Superadmin,
+			user.Active, user.Created)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM user_role ur JOIN `user` u on ur.user_id = u.id " +
+		"WHERE ur.role_id = \\? AND u.active IS NULL").
+		WithArgs(id).
+		WillReturnRows(userRows)
+	linkColumns := []string{"rl_id", "l_id", "link", "l_name", "l_active", "l_created", "c_id",
+		"c_name", "parent_id", "c_active", "c_created"}
+	linkRows := sqlmock.NewRows(linkColumns)
+	for _, roleLink := range *role.Links {
+		link := roleLink.Link
+		linkRows.AddRow(roleLink.ID, link.ID, link.Link, link.Name, link.Active,
+			link.Created, link.Category.ID, link.Category.Name,
+			link.Category.ParentID, link.Category.Active, link.Category.Created)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM role_link rl JOIN link l on rl.link_id = l.id " +
+		"JOIN category c on l.category_id = c.id WHERE rl.role_id = ?").
+		WithArgs(id).
+		WillReturnRows(linkRows)
+}
+
+func TestRoleGetShouldReturnRecordWithMembers(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	expectedRole := createRoleWithMembers(id, "admin")
+	createMockRoleGetExpectedQuery(mock, expectedRole, id)
+	roles := CreateRoles(db)
+	defer db.Close()
+	role, err := roles.Get(id)
+	if err != nil {
+		t.Errorf("Roles.Get[%d] should return result, but error: %v", id, err)
+	}
+	same := cmp.Equal(expectedRole, role)
+	if !same {
+		t.Errorf("Role object are different: %#v, %#v", expectedRole, role)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRoleSaveShouldInsertRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	role := createRole(0, "admin")
+	mock.ExpectPrepare("^INSERT INTO role\\(name\\) VALUES\\(\\?\\)").
+		ExpectExec().
+		WithArgs(role.Name).
+		WillReturnResult(sqlmock.NewResult(int64(id), 1))
+	expectedRole := createRoleWithMembers(id, "admin")
+	createMockRoleGetExpectedQuery(mock, expectedRole, id)
+	roles := CreateRoles(db)
+	defer db.Close()
+	outputRole, err := roles.Save(*role)
+	if err != nil {
+		t.Errorf("Roles.Save[%#v] should insert record, but error: %v", role, err)
+	}
+	same := cmp.Equal(outputRole, expectedRole)
+	if !same {
+		t.Errorf("Role object are different: %#v, %#v", outputRole, expectedRole)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRoleSaveShouldUpdateRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	role := createRoleWithMembers(id, "admin")
+	mock.ExpectPrepare("^UPDATE role SET name=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(role.Name, role.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	createMockRoleGetExpectedQuery(mock, role, id)
+	roles := CreateRoles(db)
+	defer db.Close()
+	outputRole, err := roles.Save(*role)
+	if err != nil {
+		t.Errorf("Roles.Save[%#v] should update record, but error: %v", role, err)
+	}
+	same := cmp.Equal(outputRole, role)
+	if !same {
+		t.Errorf("Role object are different: %#v, %#v", outputRole, role)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRoleDeleteShouldArchiveRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	role := createRoleWithMembers(id, "admin")
+	now := time.Now()
+	role.Active = &now
+	mock.ExpectPrepare("^UPDATE role SET active=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(now, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	createMockRoleGetExpectedQuery(mock, role, id)
+	roles := CreateRoles(db)
+	defer db.Close()
+	outputRole, err := roles.Delete(id, now)
+	if err != nil {
+		t.Errorf("Roles.Delete[%d, %s] should archive record, but error: %v",
+			id, now.Format("2006-01-02 15:04:05"), err)
+	}
+	same := cmp.Equal(outputRole, role)
+	if !same {
+		t.Errorf("Role object are different: %#v, %#v", outputRole, role)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRoleRetriveShouldReturnRecords(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	expectedRoles := []*model.Role{createRole(1, "admin"), createRole(2, "editor")}
+	columns := []string{"r_id", "r_name", "r_active", "r_created"}
+	rows := sqlmock.NewRows(columns)
+	for _, role := range expectedRoles {
+		rows.AddRow(role.ID, role.Name, role.Active, role.Created)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM role r WHERE r.id IN \\(\\?, \\?\\)").
+		WithArgs(1, 2).
+		WillReturnRows(rows)
+	roles := CreateRoles(db)
+	defer db.Close()
+	filters := make(map[string][]string)
+	filters["r_id"] = []string{"1", "2"}
+	outputRoles, err := roles.Retrieve(filters)
+	if err != nil {
+		t.Errorf("Roles.Retrieve[%v] should retrieve records, but error: %v", filters, err)
+	}
+	same := cmp.Equal(outputRoles, expectedRoles)
+	if !same {
+		t.Errorf("Role object are different: %#v, %#v", outputRoles, expectedRoles)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,199 @@
+package datalayer
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chytilp/links/model"
+)
+
+const (
+	// maxSearchTerms is the highest number of words in search query
+	maxSearchTerms = 10
+	// snippetRadius is number of bytes of text shown around the first match in snippet
+	snippetRadius = 60
+)
+
+// Search method returns links matching search query ordered by score and
+// total number of matching links. Query is matched against link name, url,
+// category name and public notes, link matching any word of query is found.
+// MySQL uses FULLTEXT indexes, other databases match each word of query by
+// LIKE. Only limit and offset of page are used.
+func (l *Links) Search(query string, page Page) ([]*model.SearchResult, int, error) {
+	terms, err := searchTerms(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	match, matchValues := l.match(query, terms)
+	whereClause, whereValues, err := l.where(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(whereClause) != 0 {
+		whereClause = "WHERE " + whereClause
+	}
+	values := append(matchValues, whereValues...)
+	var total int
+	countQuery := l.countPattern + "JOIN (" + match + ") m on m.link_id = l.id " + whereClause
+	if err := l.records.db.QueryRow(countQuery, values...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	selectQuery := fmt.Sprintf(l.searchPattern, match) + whereClause + " ORDER BY m.score DESC, l_id ASC"
+	limit, limitValues := buildLimit(page)
+	rows, err := l.records.db.Query(selectQuery+limit, append(values, limitValues...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var result []*model.SearchResult
+	for rows.Next() {
+		var score float64
+		link, err := l.scanRow(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &score)...)
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, &model.SearchResult{Link: link, Score: score})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	notes, err := l.publicNotes(result)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, found := range result {
+		found.Snippets = searchSnippets(found.Link, notes[found.Link.ID], terms)
+	}
+	return result, total, nil
+}
+
+// match returns sql query selecting link_id and score of links matching any
+// word of query, score grows with number of matches.
+func (l *Links) match(query string, terms []string) (string, []interface{}) {
+	if l.fullText {
+		return l.matchPattern, []interface{}{query, query, query, query, query, false, query}
+	}
+	var matches []string
+	var values []interface{}
+	for _, term := range terms {
+		pattern := likePattern(term)
+		matches = append(matches, l.likeMatchPattern)
+		values = append(values, pattern, pattern, pattern, false, pattern)
+	}
+	return "SELECT link_id, SUM(score) AS score FROM (" + strings.Join(matches, " UNION ALL ") +
+		") matches GROUP BY link_id", values
+}
+
+// publicNotes returns texts of public notes of found links by link id.
+func (l *Links) publicNotes(found []*model.SearchResult) (map[int][]string, error) {
+	notes := make(map[int][]string)
+	if len(found) == 0 {
+		return notes, nil
+	}
+	values := []interface{}{false}
+	for _, result := range found {
+		values = append(values, result.Link.ID)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(found)), ", ")
+	rows, err := l.records.db.Query(fmt.Sprintf(l.notesPattern, placeholders), values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var linkID int
+		var note string
+		if err := rows.Scan(&linkID, &note); err != nil {
+			return nil, err
+		}
+		notes[linkID] = append(notes[linkID], note)
+	}
+	return notes, rows.Err()
+}
+
+// searchTerms splits search query to distinct lower case words.
+func searchTerms(query string) ([]string, error) {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: Search query is empty", ErrInvalidFilter)
+	}
+	if len(terms) > maxSearchTerms {
+		return nil, fmt.Errorf("%w: Search query can have at most %d words", ErrInvalidFilter, maxSearchTerms)
+	}
+	return terms, nil
+}
+
+// searchSnippets returns highlighted snippets of fields of link matching terms.
+func searchSnippets(link *model.Link, notes []string, terms []string) []model.Snippet {
+	snippets := []model.Snippet{}
+	add := func(field string, text string) {
+		if highlighted, ok := highlight(text, terms); ok {
+			snippets = append(snippets, model.Snippet{Field: field, Text: highlighted})
+		}
+	}
+	add("name", link.Name)
+	add("link", link.Link)
+	add("category", link.Category.Name)
+	for _, note := range notes {
+		add("note", note)
+	}
+	return snippets
+}
+
+// highlight returns part of text around the first match of terms with all
+// matches marked by <mark></mark>, rest of text is html escaped. It returns
+// false when text does not match any term.
+func highlight(text string, terms []string) (string, bool) {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	matches := regexp.MustCompile("(?i)"+strings.Join(quoted, "|")).FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+	start := matches[0][0] - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	end := matches[0][1] + snippetRadius
+	if end > len(text) {
+		end = len(text)
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+	position := start
+	for _, match := range matches {
+		if match[1] > end {
+			break
+		}
+		snippet.WriteString(html.EscapeString(text[position:match[0]]))
+		snippet.WriteString("<mark>" + html.EscapeString(text[match[0]:match[1]]) + "</mark>")
+		position = match[1]
+	}
+	snippet.WriteString(html.EscapeString(text[position:end]))
+	if end < len(text) {
+		snippet.WriteString("…")
+	}
+	return snippet.String(), true
+}
@@ -0,0 +1,72 @@
+package datalayer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chytilp/links/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHighlightShouldMarkTerms(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		terms    []string
+		expected string
+		ok       bool
+	}{
+		{"Go <tour>", []string{"go"}, "<mark>Go</mark> &lt;tour&gt;", true},
+		{"Learn Go by go examples", []string{"go", "learn"},
+			"<mark>Learn</mark> <mark>Go</mark> by <mark>go</mark> examples", true},
+		{strings.Repeat("a", 100) + " golang " + strings.Repeat("b", 100), []string{"golang"},
+			"…" + strings.Repeat("a", 59) + " <mark>golang</mark> " + strings.Repeat("b", 59) + "…", true},
+		{"Rust book", []string{"go"}, "", false},
+	} {
+		snippet, ok := highlight(test.text, test.terms)
+		if snippet != test.expected || ok != test.ok {
+			t.Errorf("highlight[%s, %v] should return %s, %v, but returns: %s, %v", test.text, test.terms,
+				test.expected, test.ok, snippet, ok)
+		}
+	}
+}
+
+func TestLinkSearchShouldUseFullText(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	link := createLink(3, "golang tour")
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM link l JOIN category c on l.category_id = c.id "+
+		"JOIN \\(SELECT link_id, SUM\\(score\\) AS score FROM \\(SELECT id AS link_id, "+
+		"2 \\* MATCH\\(name, link\\) AGAINST \\(\\?\\) AS score (.+)\\) m on m.link_id = l.id "+
+		"WHERE l.active IS NULL AND c.active IS NULL$").
+		WithArgs("golang", "golang", "golang", "golang", "golang", false, "golang").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	columns := []string{"l_id", "link", "l_name", "l_active", "l_created", "c_id",
+		"c_name", "parent_id", "c_active", "c_created", "rating", "rating_count", "tags", "score"}
+	mock.ExpectQuery("^SELECT (.+), m.score FROM link l (.+) MATCH\\(note\\) AGAINST (.+) "+
+		"ORDER BY m.score DESC, l_id ASC LIMIT \\? OFFSET \\?$").
+		WithArgs("golang", "golang", "golang", "golang", "golang", false, "golang", 10, 0).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(link.ID, link.Link, link.Name, link.Active,
+			link.Created, link.Category.ID, link.Category.Name,
+			link.Category.ParentID, link.Category.Active, link.Category.Created,
+			link.Rating, link.RatingCount, nil, 1.5))
+	mock.ExpectQuery("^SELECT link_id, note FROM note WHERE private = \\? AND link_id IN \\(\\?\\) ORDER BY id$").
+		WithArgs(false, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"link_id", "note"}).AddRow(3, "interactive golang"))
+	links := CreateLinks(db)
+	links.fullText = true
+	defer db.Close()
+	results, total, err := links.Search("golang", Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("Links.Search should return results, but error: %v", err)
+	}
+	expected := []*model.SearchResult{{Link: link, Score: 1.5, Snippets: []model.Snippet{
+		{Field: "name", Text: "<mark>golang</mark> tour"},
+		{Field: "note", Text: "interactive <mark>golang</mark>"},
+	}}}
+	if total != 1 || !cmp.Equal(results, expected) {
+		t.Errorf("Links.Search should return %#v, but returns: %#v, %d", expected, results, total)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,84 @@
+package datalayer
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// Sessions type wrapps database methods above session table.
+// Only sha256 hash of session token is stored in db.
+type Sessions struct {
+	records       *records
+	selectPattern string
+	insertPattern string
+	deletePattern string
+}
+
+// CreateSessions creates and returns instance of Sessions struct.
+func CreateSessions(db *sql.DB) *Sessions {
+	sessions := &Sessions{
+		records: newRecords(db),
+		selectPattern: "SELECT u.id AS u_id, u.name AS u_name, u.email, u.superadmin, " +
+			"u.active AS u_active, u.created AS u_created " +
+			"FROM session s " +
+			"JOIN `user` u on s.user_id = u.id " +
+			"WHERE s.token = ? AND s.expires > ? AND u.active IS NULL",
+		insertPattern: "INSERT INTO session(token, user_id, expires) VALUES(?, ?, ?)",
+		deletePattern: "DELETE FROM session WHERE token=?",
+	}
+	return sessions
+}
+
+// Create method creates new session of user valid until expires and returns its token.
+func (s *Sessions) Create(userID int, expires time.Time) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	values := []interface{}{
+		hashToken(token),
+		userID,
+		expires,
+	}
+	if err := s.records.update(values, s.insertPattern); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// User method returns active user of valid session by session token.
+func (s *Sessions) User(token string, now time.Time) (*model.User, error) {
+	row := s.records.db.QueryRow(s.selectPattern, hashToken(token), now)
+	user := &model.User{}
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Superadmin, &user.Active,
+		&user.Created)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Delete method removes session by session token.
+func (s *Sessions) Delete(token string) error {
+	return s.records.update([]interface{}{hashToken(token)}, s.deletePattern)
+}
+
+// newToken returns new random session token.
+func newToken() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
+// hashToken returns hex encoded sha256 hash of session token.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
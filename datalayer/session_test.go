@@ -0,0 +1,72 @@
+package datalayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSessionCreateShouldStoreTokenHash(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	expires := time.Now().Add(time.Hour)
+	mock.ExpectPrepare("^INSERT INTO session\\(token, user_id, expires\\) VALUES\\(\\?, \\?, \\?\\)").
+		ExpectExec().
+		WithArgs(sqlmock.AnyArg(), 1, expires).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sessions := CreateSessions(db)
+	defer db.Close()
+	token, err := sessions.Create(1, expires)
+	if err != nil {
+		t.Errorf("Sessions.Create[%d] should create session, but error: %v", 1, err)
+	}
+	if len(token) != 64 {
+		t.Errorf("Sessions.Create[%d] returned token with wrong length: %s", 1, token)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSessionUserShouldReturnActiveUser(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	now := time.Now()
+	expectedUser := createUser(1, "petr")
+	expectedUser.Roles = nil
+	columns := []string{"u_id", "u_name", "email", "superadmin", "u_active", "u_created"}
+	mock.ExpectQuery("^SELECT (.+) FROM session s JOIN `user` u on s.user_id = u.id "+
+		"WHERE s.token = \\? AND s.expires > \\? AND u.active IS NULL").
+		WithArgs(hashToken("token"), now).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(expectedUser.ID, expectedUser.Name,
+			expectedUser.Email, expectedUser.Superadmin, expectedUser.Active, expectedUser.Created))
+	sessions := CreateSessions(db)
+	defer db.Close()
+	user, err := sessions.User("token", now)
+	if err != nil {
+		t.Errorf("Sessions.User should return user, but error: %v", err)
+	}
+	same := cmp.Equal(user, expectedUser)
+	if !same {
+		t.Errorf("User object are different: %#v, %#v", user, expectedUser)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSessionDeleteShouldRemoveSession(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	mock.ExpectPrepare("^DELETE FROM session WHERE token=\\?").
+		ExpectExec().
+		WithArgs(hashToken("token")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sessions := CreateSessions(db)
+	defer db.Close()
+	if err := sessions.Delete("token"); err != nil {
+		t.Errorf("Sessions.Delete should remove session, but error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
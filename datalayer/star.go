@@ -0,0 +1,75 @@
+package datalayer
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/chytilp/links/model"
+)
+
+const (
+	// MinStars is the lowest rating of link.
+	MinStars = 1
+	// MaxStars is the highest rating of link.
+	MaxStars = 5
+)
+
+// ErrInvalidStars is returned when rating is out of MinStars-MaxStars range.
+var ErrInvalidStars = errors.New("stars must be between 1 and 5")
+
+// Stars type wrapps database methods above star table.
+type Stars struct {
+	records       *records
+	selectPattern string
+	insertPattern string
+	updatePattern string
+	deletePattern string
+}
+
+// CreateStars creates and returns instance of Stars struct.
+func CreateStars(db *sql.DB) *Stars {
+	stars := &Stars{
+		records: newRecords(db),
+		selectPattern: "SELECT s.id, s.user_id, s.link_id, s.stars, s.created " +
+			"FROM star s " +
+			"WHERE s.user_id = ? AND s.link_id = ?",
+		insertPattern: "INSERT INTO star(user_id, link_id, stars) VALUES(?, ?, ?)",
+		updatePattern: "UPDATE star SET stars=? WHERE id=?",
+		deletePattern: "DELETE FROM star WHERE user_id=? AND link_id=?",
+	}
+	return stars
+}
+
+// Get method returns rating of link by user.
+func (s *Stars) Get(userID int, linkID int) (*model.Star, error) {
+	row := s.records.db.QueryRow(s.selectPattern, userID, linkID)
+	star := &model.Star{User: &model.User{}, Link: &model.Link{}}
+	err := row.Scan(&star.ID, &star.User.ID, &star.Link.ID, &star.Stars, &star.Created)
+	if err != nil {
+		return nil, err
+	}
+	return star, nil
+}
+
+// Set method sets or changes rating of link by user.
+func (s *Stars) Set(userID int, linkID int, stars int) (*model.Star, error) {
+	if stars < MinStars || stars > MaxStars {
+		return nil, ErrInvalidStars
+	}
+	existing, err := s.Get(userID, linkID)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = s.records.insert([]interface{}{userID, linkID, stars}, s.insertPattern)
+	case err == nil:
+		err = s.records.update([]interface{}{stars, existing.ID}, s.updatePattern)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(userID, linkID)
+}
+
+// Delete method removes rating of link by user.
+func (s *Stars) Delete(userID int, linkID int) error {
+	return s.records.update([]interface{}{userID, linkID}, s.deletePattern)
+}
@@ -0,0 +1,110 @@
+package datalayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chytilp/links/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func createStar(id int, userID int, linkID int, stars int) *model.Star {
+	created := time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC)
+	return &model.Star{
+		ID:      id,
+		User:    &model.User{ID: userID},
+		Link:    &model.Link{ID: linkID},
+		Stars:   stars,
+		Created: &created,
+	}
+}
+
+func createMockStarGetExpectedQuery(mock sqlmock.Sqlmock, star *model.Star, userID int, linkID int) {
+	columns := []string{"id", "user_id", "link_id", "stars", "created"}
+	rows := sqlmock.NewRows(columns)
+	if star != nil {
+		rows.AddRow(star.ID, star.User.ID, star.Link.ID, star.Stars, star.Created)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM star s WHERE s.user_id = \\? AND s.link_id = \\?").
+		WithArgs(userID, linkID).
+		WillReturnRows(rows)
+}
+
+func TestStarSetShouldInsertRating(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	expectedStar := createStar(1, 2, 3, 4)
+	createMockStarGetExpectedQuery(mock, nil, 2, 3)
+	mock.ExpectPrepare("^INSERT INTO star\\(user_id, link_id, stars\\) VALUES\\(\\?, \\?, \\?\\)").
+		ExpectExec().
+		WithArgs(2, 3, 4).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	createMockStarGetExpectedQuery(mock, expectedStar, 2, 3)
+	stars := CreateStars(db)
+	defer db.Close()
+	star, err := stars.Set(2, 3, 4)
+	if err != nil {
+		t.Errorf("Stars.Set should insert rating, but error: %v", err)
+	}
+	same := cmp.Equal(star, expectedStar)
+	if !same {
+		t.Errorf("Star object are different: %#v, %#v", star, expectedStar)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStarSetShouldChangeRating(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	expectedStar := createStar(1, 2, 3, 5)
+	createMockStarGetExpectedQuery(mock, createStar(1, 2, 3, 4), 2, 3)
+	mock.ExpectPrepare("^UPDATE star SET stars=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	createMockStarGetExpectedQuery(mock, expectedStar, 2, 3)
+	stars := CreateStars(db)
+	defer db.Close()
+	star, err := stars.Set(2, 3, 5)
+	if err != nil {
+		t.Errorf("Stars.Set should change rating, but error: %v", err)
+	}
+	same := cmp.Equal(star, expectedStar)
+	if !same {
+		t.Errorf("Star object are different: %#v, %#v", star, expectedStar)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStarSetShouldRejectOutOfRange(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	stars := CreateStars(db)
+	defer db.Close()
+	for _, value := range []int{0, 6} {
+		if _, err := stars.Set(2, 3, value); err != ErrInvalidStars {
+			t.Errorf("Stars.Set[%d] should reject rating, but error: %v", value, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStarDeleteShouldRemoveRating(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	mock.ExpectPrepare("^DELETE FROM star WHERE user_id=\\? AND link_id=\\?").
+		ExpectExec().
+		WithArgs(2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	stars := CreateStars(db)
+	defer db.Close()
+	if err := stars.Delete(2, 3); err != nil {
+		t.Errorf("Stars.Delete should remove rating, but error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,124 @@
+package datalayer
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+// LinkStore is interface of repository of links.
+type LinkStore interface {
+	ForUser(user *model.User) LinkStore
+	IncludeInactive() LinkStore
+	AllowDuplicate() LinkStore
+	CanWrite(id int) (bool, error)
+	Get(id int) (*model.Link, error)
+	Save(link model.Link) (*model.Link, error)
+	Patch(id int, patch LinkPatch) (*model.Link, error)
+	Delete(id int, time time.Time) (*model.Link, error)
+	Restore(id int) (*model.Link, error)
+	Retrieve(filters map[string][]string) ([]*model.Link, error)
+	Count(filters map[string][]string) (int, error)
+	Search(query string, page Page) ([]*model.SearchResult, int, error)
+}
+
+// CategoryStore is interface of repository of categories.
+type CategoryStore interface {
+	Get(id int) (*model.Category, error)
+	Save(category model.Category) (*model.Category, error)
+	Delete(id int, time time.Time) (*model.Category, error)
+	DeleteCascade(id int, time time.Time) (*model.Category, error)
+	ActiveLinksCount(id int) (int, error)
+	Retrieve(filters map[string][]string) ([]*model.Category, error)
+	Subtree(id int) (*model.CategoryNode, error)
+	Path(id int) ([]*model.Category, error)
+	Tree() ([]*model.CategoryNode, error)
+	DescendantIDs(id int) ([]int, error)
+}
+
+// UserStore is interface of repository of users.
+type UserStore interface {
+	Get(id int) (*model.User, error)
+	FindByEmail(email string) (*model.User, error)
+	Save(user model.User) (*model.User, error)
+	Delete(id int, time time.Time) (*model.User, error)
+	Retrieve(filters map[string][]string) ([]*model.User, error)
+	VerifyPassword(id int, password string) (bool, error)
+}
+
+// RoleStore is interface of repository of roles.
+type RoleStore interface {
+	Get(id int) (*model.Role, error)
+	Save(role model.Role) (*model.Role, error)
+	Delete(id int, time time.Time) (*model.Role, error)
+	Retrieve(filters map[string][]string) ([]*model.Role, error)
+}
+
+// UserRoleStore is interface of repository of roles assigned to users.
+type UserRoleStore interface {
+	Get(id int) (*model.UserRole, error)
+	Find(userID int, roleID int) (*model.UserRole, error)
+	Save(userRole model.UserRole) (*model.UserRole, error)
+	Delete(id int) error
+}
+
+// SessionStore is interface of repository of login sessions.
+type SessionStore interface {
+	Create(userID int, expires time.Time) (string, error)
+	User(token string, now time.Time) (*model.User, error)
+	Delete(token string) error
+}
+
+// StarStore is interface of repository of link ratings.
+type StarStore interface {
+	Get(userID int, linkID int) (*model.Star, error)
+	Set(userID int, linkID int, stars int) (*model.Star, error)
+	Delete(userID int, linkID int) error
+}
+
+// NoteStore is interface of repository of link notes.
+type NoteStore interface {
+	Get(id int) (*model.Note, error)
+	ForLink(linkID int, userID int) ([]*model.Note, error)
+	Save(note model.Note) (*model.Note, error)
+	Delete(id int) error
+}
+
+// TagStore is interface of repository of tags.
+type TagStore interface {
+	ForUser(user *model.User) TagStore
+	Get(id int) (*model.Tag, error)
+	Retrieve() ([]*model.Tag, error)
+	Rename(id int, name string) (*model.Tag, error)
+	Merge(sourceID int, targetID int) (*model.Tag, error)
+}
+
+// Store type groups repositories of one storage backend.
+type Store struct {
+	Links      LinkStore
+	Categories CategoryStore
+	Users      UserStore
+	Roles      RoleStore
+	UserRoles  UserRoleStore
+	Sessions   SessionStore
+	Stars      StarStore
+	Notes      NoteStore
+	Tags       TagStore
+}
+
+// CreateStore creates and returns repositories above db opened by Open.
+// Sql of repositories is common for all supported drivers.
+func CreateStore(db *sql.DB) *Store {
+	return &Store{
+		Links:      CreateLinks(db),
+		Categories: CreateCategories(db),
+		Users:      CreateUsers(db),
+		Roles:      CreateRoles(db),
+		UserRoles:  CreateUserRoles(db),
+		Sessions:   CreateSessions(db),
+		Stars:      CreateStars(db),
+		Notes:      CreateNotes(db),
+		Tags:       CreateTags(db),
+	}
+}
@@ -0,0 +1,636 @@
+package datalayer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/chytilp/links/config"
+	"github.com/chytilp/links/migrations"
+	"github.com/chytilp/links/model"
+)
+
+// openSQLiteStore opens migrated sqlite database in temporary directory.
+func openSQLiteStore(t *testing.T) (*sql.DB, *Store) {
+	db, err := Open(config.DbConfig{
+		Driver:       config.SQLite,
+		Path:         filepath.Join(t.TempDir(), "links.db"),
+		MaxOpenConns: 1,
+		MaxIdleConns: 1,
+	})
+	if err != nil {
+		t.Fatalf("Open should open sqlite database, but error: %v", err)
+	}
+	migrator, err := migrations.CreateMigrator(db, config.SQLite)
+	if err != nil {
+		t.Fatalf("CreateMigrator should return migrator, but error: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Migrator.Up should create schema, but error: %v", err)
+	}
+	return db, CreateStore(db)
+}
+
+// testStores runs test against every store implementation.
+func testStores(t *testing.T, test func(t *testing.T, store *Store)) {
+	t.Run("sqlite", func(t *testing.T) {
+		db, store := openSQLiteStore(t)
+		defer db.Close()
+		test(t, store)
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, CreateMemoryStore())
+	})
+}
+
+func saveTestUser(t *testing.T, store *Store, name string) *model.User {
+	user, err := store.Users.Save(model.User{Name: name, Email: name + "@example.com",
+		Password: "secret"})
+	if err != nil {
+		t.Fatalf("Users.Save should save user %s, but error: %v", name, err)
+	}
+	return user
+}
+
+func TestStoreCategoryTree(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		root, err := store.Categories.Save(model.Category{Name: "root"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save root, but error: %v", err)
+		}
+		child, err := store.Categories.Save(model.Category{Name: "child", ParentID: root.ID})
+		if err != nil {
+			t.Fatalf("Categories.Save should save child, but error: %v", err)
+		}
+		path, err := store.Categories.Path(child.ID)
+		if err != nil {
+			t.Errorf("Categories.Path should return path, but error: %v", err)
+		}
+		if len(path) != 2 || path[0].ID != root.ID || path[1].ID != child.ID {
+			t.Errorf("Categories.Path should return root and child, but returns: %v", path)
+		}
+		ids, err := store.Categories.DescendantIDs(root.ID)
+		if err != nil {
+			t.Errorf("Categories.DescendantIDs should return ids, but error: %v", err)
+		}
+		if len(ids) != 2 {
+			t.Errorf("Categories.DescendantIDs should return 2 ids, but returns: %v", ids)
+		}
+		_, err = store.Categories.Save(model.Category{ID: root.ID, Name: "root", ParentID: child.ID})
+		if err != ErrCategoryCycle {
+			t.Errorf("Categories.Save should reject cycle, but error: %v", err)
+		}
+	})
+}
+
+func TestStoreLinkVisibilityAndRating(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		owner := saveTestUser(t, store, "owner")
+		other := saveTestUser(t, store, "other")
+		category, err := store.Categories.Save(model.Category{Name: "golang"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		link, err := store.Links.ForUser(owner).Save(model.Link{Link: "https://golang.org",
+			Name: "Go", Category: category})
+		if err != nil {
+			t.Fatalf("Links.Save should save link, but error: %v", err)
+		}
+		if _, err := store.Links.ForUser(other).Get(link.ID); err != sql.ErrNoRows {
+			t.Errorf("Links.Get should hide link from other user, but error: %v", err)
+		}
+		canWrite, err := store.Links.ForUser(other).CanWrite(link.ID)
+		if err != nil || canWrite {
+			t.Errorf("Links.CanWrite should deny other user, but returns: %v, %v", canWrite, err)
+		}
+		if _, err := store.Stars.Set(owner.ID, link.ID, 4); err != nil {
+			t.Errorf("Stars.Set should save rating, but error: %v", err)
+		}
+		if _, err := store.Stars.Set(other.ID, link.ID, 2); err != nil {
+			t.Errorf("Stars.Set should save rating, but error: %v", err)
+		}
+		found, err := store.Links.ForUser(owner).Get(link.ID)
+		if err != nil {
+			t.Fatalf("Links.Get should return link to owner, but error: %v", err)
+		}
+		if found.Rating != 3 || found.RatingCount != 2 {
+			t.Errorf("Link should have rating 3 from 2 users, but has %v from %d", found.Rating,
+				found.RatingCount)
+		}
+		links, err := store.Links.ForUser(other).Retrieve(map[string][]string{})
+		if err != nil || len(links) != 0 {
+			t.Errorf("Links.Retrieve should return no links to other user, but returns: %v, %v", links, err)
+		}
+	})
+}
+
+// TestStoreSharedLinksOnSQLite covers links shared by user_link and
+// role_link, which can be created only in database.
+func TestStoreSharedLinksOnSQLite(t *testing.T) {
+	db, store := openSQLiteStore(t)
+	defer db.Close()
+	owner := saveTestUser(t, store, "owner")
+	reader := saveTestUser(t, store, "reader")
+	stranger := saveTestUser(t, store, "stranger")
+	category, err := store.Categories.Save(model.Category{Name: "golang"})
+	if err != nil {
+		t.Fatalf("Categories.Save should save category, but error: %v", err)
+	}
+	byUser, err := store.Links.ForUser(owner).Save(model.Link{Link: "https://golang.org",
+		Name: "Go", Category: category})
+	if err != nil {
+		t.Fatalf("Links.Save should save link, but error: %v", err)
+	}
+	byRole, err := store.Links.ForUser(owner).Save(model.Link{Link: "https://go.dev/blog",
+		Name: "Go blog", Category: category})
+	if err != nil {
+		t.Fatalf("Links.Save should save link, but error: %v", err)
+	}
+	role, err := store.Roles.Save(model.Role{Name: "gophers"})
+	if err != nil {
+		t.Fatalf("Roles.Save should save role, but error: %v", err)
+	}
+	if _, err := store.UserRoles.Save(model.UserRole{User: reader, Role: role}); err != nil {
+		t.Fatalf("UserRoles.Save should assign role, but error: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO user_link(user_id, link_id, owner) VALUES(?, ?, ?)",
+		reader.ID, byUser.ID, false); err != nil {
+		t.Fatalf("user_link should be inserted, but error: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO role_link(role_id, link_id) VALUES(?, ?)", role.ID, byRole.ID); err != nil {
+		t.Fatalf("role_link should be inserted, but error: %v", err)
+	}
+
+	readerLinks := store.Links.ForUser(reader)
+	for _, link := range []*model.Link{byUser, byRole} {
+		if _, err := readerLinks.Get(link.ID); err != nil {
+			t.Errorf("Links.Get should return shared link %d, but error: %v", link.ID, err)
+		}
+		canWrite, err := readerLinks.CanWrite(link.ID)
+		if err != nil || canWrite {
+			t.Errorf("Links.CanWrite should deny shared link %d, but returns: %v, %v", link.ID, canWrite, err)
+		}
+		if _, err := readerLinks.Delete(link.ID, time.Now()); err != ErrForbidden {
+			t.Errorf("Links.Delete should deny shared link %d, but error: %v", link.ID, err)
+		}
+		if _, err := store.Links.ForUser(stranger).Get(link.ID); err != sql.ErrNoRows {
+			t.Errorf("Links.Get should hide shared link %d from stranger, but error: %v", link.ID, err)
+		}
+	}
+	links, err := readerLinks.Retrieve(map[string][]string{})
+	if err != nil || len(links) != 2 {
+		t.Errorf("Links.Retrieve should return both shared links, but returns: %v, %v", links, err)
+	}
+	canWrite, err := store.Links.ForUser(owner).CanWrite(byRole.ID)
+	if err != nil || !canWrite {
+		t.Errorf("Links.CanWrite should allow owner, but returns: %v, %v", canWrite, err)
+	}
+}
+
+func TestStoreUserPasswordAndRoles(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		role, err := store.Roles.Save(model.Role{Name: "editor"})
+		if err != nil {
+			t.Fatalf("Roles.Save should save role, but error: %v", err)
+		}
+		user, err := store.Users.Save(model.User{Name: "alice", Email: "alice@example.com",
+			Password: "secret", Roles: &[]model.UserRole{{Role: role}}})
+		if err != nil {
+			t.Fatalf("Users.Save should save user, but error: %v", err)
+		}
+		if user.Roles == nil || len(*user.Roles) != 1 {
+			t.Errorf("User should have one role, but has: %v", user.Roles)
+		}
+		ok, err := store.Users.VerifyPassword(user.ID, "secret")
+		if err != nil || !ok {
+			t.Errorf("Users.VerifyPassword should accept password, but returns: %v, %v", ok, err)
+		}
+		ok, err = store.Users.VerifyPassword(user.ID, "wrong")
+		if err != nil || ok {
+			t.Errorf("Users.VerifyPassword should reject password, but returns: %v, %v", ok, err)
+		}
+		if _, err := store.UserRoles.Save(model.UserRole{User: user, Role: role}); err != ErrDuplicateUserRole {
+			t.Errorf("UserRoles.Save should reject duplicate, but error: %v", err)
+		}
+	})
+}
+
+func TestStoreUserEmailIgnoresCase(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		user := saveTestUser(t, store, "alice")
+		found, err := store.Users.FindByEmail("Alice@Example.com")
+		if err != nil || found.ID != user.ID {
+			t.Errorf("Users.FindByEmail should ignore case, but returns: %v, %v", found, err)
+		}
+		_, err = store.Users.Save(model.User{Name: "other", Email: "ALICE@example.com", Password: "secret"})
+		if err != ErrDuplicateEmail {
+			t.Errorf("Users.Save should reject email differing only in case, but error: %v", err)
+		}
+	})
+}
+
+func TestIsDuplicateKeyShouldDetectUniqueViolation(t *testing.T) {
+	db, store := openSQLiteStore(t)
+	defer db.Close()
+	saveTestUser(t, store, "alice")
+	_, err := db.Exec("INSERT INTO `user`(name, email, password, superadmin) VALUES(?, ?, ?, ?)",
+		"other", "alice@example.com", "secret", false)
+	if !isDuplicateKey(err) {
+		t.Errorf("isDuplicateKey should detect unique violation, but error: %v", err)
+	}
+	if isDuplicateKey(sql.ErrNoRows) {
+		t.Errorf("isDuplicateKey should not detect other errors")
+	}
+}
+
+func TestStoreSessionAndNotes(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		user := saveTestUser(t, store, "bob")
+		now := time.Now()
+		token, err := store.Sessions.Create(user.ID, now.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Sessions.Create should create session, but error: %v", err)
+		}
+		sessionUser, err := store.Sessions.User(token, now)
+		if err != nil || sessionUser.ID != user.ID {
+			t.Errorf("Sessions.User should return user, but returns: %v, %v", sessionUser, err)
+		}
+		if _, err := store.Sessions.User(token, now.Add(2*time.Hour)); err != sql.ErrNoRows {
+			t.Errorf("Sessions.User should reject expired session, but error: %v", err)
+		}
+		category, err := store.Categories.Save(model.Category{Name: "notes"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		link, err := store.Links.ForUser(user).Save(model.Link{Link: "https://example.com",
+			Name: "Example", Category: category})
+		if err != nil {
+			t.Fatalf("Links.Save should save link, but error: %v", err)
+		}
+		_, err = store.Notes.Save(model.Note{User: user, Link: link, Note: "mine", Private: true})
+		if err != nil {
+			t.Fatalf("Notes.Save should save note, but error: %v", err)
+		}
+		notes, err := store.Notes.ForLink(link.ID, user.ID+1)
+		if err != nil || len(notes) != 0 {
+			t.Errorf("Notes.ForLink should hide private note, but returns: %v, %v", notes, err)
+		}
+		notes, err = store.Notes.ForLink(link.ID, user.ID)
+		if err != nil || len(notes) != 1 {
+			t.Errorf("Notes.ForLink should return own note, but returns: %v, %v", notes, err)
+		}
+	})
+}
+
+func TestStoreLinkPaging(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		category, err := store.Categories.Save(model.Category{Name: "paging"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		var ids []int
+		for _, name := range []string{"b", "a", "c", "a"} {
+			link, err := store.Links.AllowDuplicate().Save(model.Link{Link: "https://" + name + ".org", Name: name,
+				Category: category})
+			if err != nil {
+				t.Fatalf("Links.Save should save link, but error: %v", err)
+			}
+			ids = append(ids, link.ID)
+		}
+		expectIDs := func(filters map[string][]string, expected ...int) {
+			links, err := store.Links.Retrieve(filters)
+			if err != nil {
+				t.Errorf("Links.Retrieve[%v] should return links, but error: %v", filters, err)
+				return
+			}
+			var found []int
+			for _, link := range links {
+				found = append(found, link.ID)
+			}
+			if fmt.Sprint(found) != fmt.Sprint(expected) {
+				t.Errorf("Links.Retrieve[%v] should return %v, but returns %v", filters, expected, found)
+			}
+		}
+		expectIDs(map[string][]string{"sort": {"l_name,-l_id"}}, ids[3], ids[1], ids[0], ids[2])
+		expectIDs(map[string][]string{"sort": {"l_name"}, "limit": {"2"}, "offset": {"1"}}, ids[3], ids[0])
+		expectIDs(map[string][]string{"limit": {"2"}, "after": {strconv.Itoa(ids[1])}}, ids[2], ids[3])
+		expectIDs(map[string][]string{"offset": {"10"}})
+		count, err := store.Links.Count(map[string][]string{"l_name": {"a"}, "limit": {"1"}})
+		if err != nil || count != 2 {
+			t.Errorf("Links.Count should return 2, but returns: %d, %v", count, err)
+		}
+	})
+}
+
+func TestStoreLinkFilterOperators(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		category, err := store.Categories.Save(model.Category{Name: "operators"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		var ids []int
+		for _, name := range []string{"Go tour", "Rust book", "Effective Go"} {
+			link, err := store.Links.AllowDuplicate().Save(model.Link{Link: "https://example.org", Name: name,
+				Category: category})
+			if err != nil {
+				t.Fatalf("Links.Save should save link, but error: %v", err)
+			}
+			ids = append(ids, link.ID)
+		}
+		if _, err := store.Links.Delete(ids[2], time.Now()); err != nil {
+			t.Fatalf("Links.Delete should archive link, but error: %v", err)
+		}
+		for _, test := range []struct {
+			filters  map[string][]string
+			expected []int
+		}{
+			{map[string][]string{"l_name[like]": {"go"}}, []int{ids[0], ids[2]}},
+			{map[string][]string{"l_name[like]": {"go"}, "l_active[null]": {"true"}}, []int{ids[0]}},
+			{map[string][]string{"l_active[null]": {"false"}}, []int{ids[2]}},
+			{map[string][]string{"l_id[ne]": {strconv.Itoa(ids[0])}}, []int{ids[1], ids[2]}},
+			{map[string][]string{"l_id[gt]": {strconv.Itoa(ids[0])}, "l_id[lte]": {strconv.Itoa(ids[1])}},
+				[]int{ids[1]}},
+			{map[string][]string{"l_created[lt]": {"2000-01-01T00:00:00Z"}}, nil},
+		} {
+			links, err := store.Links.IncludeInactive().Retrieve(test.filters)
+			if err != nil {
+				t.Errorf("Links.Retrieve[%v] should return links, but error: %v", test.filters, err)
+				continue
+			}
+			var found []int
+			for _, link := range links {
+				found = append(found, link.ID)
+			}
+			if fmt.Sprint(found) != fmt.Sprint(test.expected) {
+				t.Errorf("Links.Retrieve[%v] should return %v, but returns %v", test.filters, test.expected, found)
+			}
+		}
+	})
+}
+
+func TestStoreLinkShouldHideInactive(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		category, err := store.Categories.Save(model.Category{Name: "inactive"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		link, err := store.Links.Save(model.Link{Link: "https://example.org", Name: "example",
+			Category: category})
+		if err != nil {
+			t.Fatalf("Links.Save should save link, but error: %v", err)
+		}
+		if _, err := store.Categories.Delete(category.ID, time.Now()); err != nil {
+			t.Fatalf("Categories.Delete should archive category, but error: %v", err)
+		}
+		if _, err := store.Links.Get(link.ID); err != sql.ErrNoRows {
+			t.Errorf("Links.Get should hide link in archived category, but error: %v", err)
+		}
+		count, err := store.Links.Count(map[string][]string{})
+		if err != nil || count != 0 {
+			t.Errorf("Links.Count should not count link in archived category, but returns: %d, %v", count, err)
+		}
+		links, err := store.Links.IncludeInactive().Retrieve(map[string][]string{})
+		if err != nil || len(links) != 1 {
+			t.Errorf("Links.Retrieve should return inactive link, but returns: %v, %v", links, err)
+		}
+		deleted, err := store.Links.Delete(link.ID, time.Now())
+		if err != nil || deleted.Active == nil {
+			t.Errorf("Links.Delete should return archived link, but returns: %v, %v", deleted, err)
+		}
+		restored, err := store.Links.Restore(link.ID)
+		if err != nil || restored.Active != nil {
+			t.Errorf("Links.Restore should activate link, but returns: %v, %v", restored, err)
+		}
+	})
+}
+
+func TestStoreLinkSearch(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		user := saveTestUser(t, store, "searcher")
+		golang, err := store.Categories.Save(model.Category{Name: "Golang"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		other, err := store.Categories.Save(model.Category{Name: "Other"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		var links []*model.Link
+		for _, link := range []model.Link{
+			{Link: "https://go.dev/tour", Name: "Tour of Go", Category: golang},
+			{Link: "https://example.org", Name: "Example", Category: other},
+			{Link: "https://doc.rust-lang.org/book", Name: "Rust book", Category: other},
+		} {
+			saved, err := store.Links.ForUser(user).Save(link)
+			if err != nil {
+				t.Fatalf("Links.Save should save link, but error: %v", err)
+			}
+			links = append(links, saved)
+		}
+		_, err = store.Notes.Save(model.Note{User: user, Link: links[1], Note: "examples in go"})
+		if err != nil {
+			t.Fatalf("Notes.Save should save note, but error: %v", err)
+		}
+		_, err = store.Notes.Save(model.Note{User: user, Link: links[2], Note: "go private", Private: true})
+		if err != nil {
+			t.Fatalf("Notes.Save should save note, but error: %v", err)
+		}
+		results, total, err := store.Links.ForUser(user).Search("go", Page{})
+		if err != nil {
+			t.Fatalf("Links.Search should return results, but error: %v", err)
+		}
+		if total != 2 || len(results) != 2 || results[0].Link.ID != links[0].ID || results[1].Link.ID != links[1].ID {
+			t.Fatalf("Links.Search should return tour and example links, but returns: %v, %d", results, total)
+		}
+		if len(results[1].Snippets) != 1 || results[1].Snippets[0].Text != "examples in <mark>go</mark>" {
+			t.Errorf("Links.Search should highlight note, but returns: %v", results[1].Snippets)
+		}
+		results, total, err = store.Links.ForUser(user).Search("go", Page{Limit: 1, Offset: 1})
+		if err != nil || total != 2 || len(results) != 1 || results[0].Link.ID != links[1].ID {
+			t.Errorf("Links.Search should return second page, but returns: %v, %d, %v", results, total, err)
+		}
+		results, total, err = store.Links.ForUser(user).Search("rust tour", Page{})
+		if err != nil || total != 2 || len(results) != 2 {
+			t.Errorf("Links.Search should return links matching any word, but returns: %v, %d, %v",
+				results, total, err)
+		}
+		if _, _, err := store.Links.Search("  ", Page{}); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Links.Search should reject empty query, but error: %v", err)
+		}
+	})
+}
+
+func TestStoreLinkTags(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		category, err := store.Categories.Save(model.Category{Name: "tags"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		var ids []int
+		for _, tags := range [][]string{{"Go", "db"}, {"go"}, {"db"}} {
+			link, err := store.Links.AllowDuplicate().Save(model.Link{Link: "https://example.org",
+				Name: "example", Category: category, Tags: tags})
+			if err != nil {
+				t.Fatalf("Links.Save should save link, but error: %v", err)
+			}
+			ids = append(ids, link.ID)
+		}
+		link, err := store.Links.AllowDuplicate().Save(model.Link{ID: ids[1], Link: "https://example.org",
+			Name: "changed", Category: category})
+		if err != nil || fmt.Sprint(link.Tags) != "[go]" {
+			t.Errorf("Links.Save without tags should keep tags, but returns: %v, %v", link, err)
+		}
+		expectIDs := func(filters map[string][]string, expected ...int) {
+			links, err := store.Links.Retrieve(filters)
+			if err != nil {
+				t.Errorf("Links.Retrieve[%v] should return links, but error: %v", filters, err)
+				return
+			}
+			var found []int
+			for _, link := range links {
+				found = append(found, link.ID)
+			}
+			if fmt.Sprint(found) != fmt.Sprint(expected) {
+				t.Errorf("Links.Retrieve[%v] should return %v, but returns %v", filters, expected, found)
+			}
+		}
+		expectIDs(map[string][]string{"tag": {"go", "db"}}, ids...)
+		expectIDs(map[string][]string{"tag": {"go", "db"}, "tag_match": {"all"}}, ids[0])
+		expectIDs(map[string][]string{"tag": {"GO"}}, ids[0], ids[1])
+		if _, err := store.Links.Retrieve(map[string][]string{"tag_match": {"some"}}); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Links.Retrieve should reject tag_match, but error: %v", err)
+		}
+
+		tags, err := store.Tags.Retrieve()
+		if err != nil || len(tags) != 2 || tags[0].Name != "db" || tags[0].LinkCount != 2 {
+			t.Fatalf("Tags.Retrieve should return db and go used twice, but returns: %v, %v", tags, err)
+		}
+		db, goTag := tags[0], tags[1]
+		tags, err = store.Tags.ForUser(&model.User{ID: 99}).Retrieve()
+		if err != nil || len(tags) != 2 || tags[0].LinkCount != 0 || tags[1].LinkCount != 0 {
+			t.Errorf("Tags.Retrieve for user should count only visible links, but returns: %v, %v", tags, err)
+		}
+		if _, err := store.Tags.Rename(db.ID, "go"); err != ErrDuplicateTag {
+			t.Errorf("Tags.Rename should reject name of another tag, but error: %v", err)
+		}
+		renamed, err := store.Tags.Rename(db.ID, "Database")
+		if err != nil || renamed.Name != "database" {
+			t.Errorf("Tags.Rename should rename tag, but returns: %v, %v", renamed, err)
+		}
+		merged, err := store.Tags.Merge(db.ID, goTag.ID)
+		if err != nil || merged.LinkCount != 3 {
+			t.Errorf("Tags.Merge should move links to target tag, but returns: %v, %v", merged, err)
+		}
+		if _, err := store.Tags.Get(db.ID); err != sql.ErrNoRows {
+			t.Errorf("Tags.Merge should delete source tag, but error: %v", err)
+		}
+		link, err = store.Links.Get(ids[0])
+		if err != nil || fmt.Sprint(link.Tags) != "[go]" {
+			t.Errorf("Link should have only tag go after merge, but returns: %v, %v", link, err)
+		}
+	})
+}
+
+func TestStoreLinkDuplicates(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		category, err := store.Categories.Save(model.Category{Name: "duplicates"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		first, err := store.Links.Save(model.Link{Link: "https://example.org/a", Name: "a", Category: category})
+		if err != nil {
+			t.Fatalf("Links.Save should save link, but error: %v", err)
+		}
+		var duplicateErr *DuplicateLinkError
+		_, err = store.Links.Save(model.Link{Link: "https://EXAMPLE.org/a/?utm_medium=mail", Name: "b",
+			Category: category})
+		if !errors.As(err, &duplicateErr) || duplicateErr.ExistingID != first.ID {
+			t.Errorf("Links.Save should reject duplicate of link %d, but error: %v", first.ID, err)
+		}
+		second, err := store.Links.AllowDuplicate().Save(model.Link{Link: "https://example.org/a", Name: "b",
+			Category: category})
+		if err != nil {
+			t.Fatalf("Links.Save with allowed duplicate should save link, but error: %v", err)
+		}
+		other, err := store.Links.Save(model.Link{Link: "https://example.org/b", Name: "c", Category: category})
+		if err != nil {
+			t.Fatalf("Links.Save should save link, but error: %v", err)
+		}
+		other.Link = "https://example.org/a#top"
+		if _, err := store.Links.Save(*other); !errors.Is(err, ErrDuplicateLink) {
+			t.Errorf("Links.Save should reject update to duplicate url, but error: %v", err)
+		}
+		second.Name = "renamed"
+		if _, err := store.Links.AllowDuplicate().Save(*second); err != nil {
+			t.Errorf("Links.Save with allowed duplicate should update link, but error: %v", err)
+		}
+		if _, err := store.Links.Save(*first); err != nil {
+			t.Errorf("Links.Save should update link with its own url, but error: %v", err)
+		}
+		if _, err := store.Links.Delete(first.ID, time.Now()); err != nil {
+			t.Fatalf("Links.Delete should archive link, but error: %v", err)
+		}
+		if _, err := store.Links.Save(model.Link{Link: "https://example.org/a", Name: "d",
+			Category: category}); err != nil {
+			t.Errorf("Links.Save should not check duplicates against archived link, but error: %v", err)
+		}
+
+		alice, bob := saveTestUser(t, store, "alice"), saveTestUser(t, store, "bob")
+		private := model.Link{Link: "https://example.org/private", Name: "private", Category: category}
+		if _, err := store.Links.ForUser(alice).Save(private); err != nil {
+			t.Fatalf("Links.Save should save link, but error: %v", err)
+		}
+		own, err := store.Links.ForUser(bob).Save(private)
+		if err != nil {
+			t.Fatalf("Links.Save should not check duplicates against not visible link, but error: %v", err)
+		}
+		_, err = store.Links.ForUser(bob).Save(private)
+		if !errors.As(err, &duplicateErr) || duplicateErr.ExistingID != own.ID {
+			t.Errorf("Links.Save should reject duplicate of visible link %d, but error: %v", own.ID, err)
+		}
+	})
+}
+
+func TestStoreLinkPatch(t *testing.T) {
+	testStores(t, func(t *testing.T, store *Store) {
+		golang, err := store.Categories.Save(model.Category{Name: "golang"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		other, err := store.Categories.Save(model.Category{Name: "other"})
+		if err != nil {
+			t.Fatalf("Categories.Save should save category, but error: %v", err)
+		}
+		link, err := store.Links.Save(model.Link{Link: "https://go.dev", Name: "Go", Category: golang,
+			Tags: []string{"go"}})
+		if err != nil {
+			t.Fatalf("Links.Save should save link, but error: %v", err)
+		}
+		if _, err := store.Links.Save(model.Link{Link: "https://example.org", Name: "Example",
+			Category: golang}); err != nil {
+			t.Fatalf("Links.Save should save link, but error: %v", err)
+		}
+		name := "Go dev"
+		patched, err := store.Links.Patch(link.ID, LinkPatch{Name: &name, CategoryID: &other.ID})
+		if err != nil || patched.Name != name || patched.Link != link.Link || patched.Category.ID != other.ID ||
+			fmt.Sprint(patched.Tags) != "[go]" {
+			t.Errorf("Links.Patch should change name and category only, but returns: %v, %v", patched, err)
+		}
+		patched, err = store.Links.Patch(link.ID, LinkPatch{Tags: []string{}})
+		if err != nil || patched.Name != name || len(patched.Tags) != 0 {
+			t.Errorf("Links.Patch should remove tags only, but returns: %v, %v", patched, err)
+		}
+		url := "https://EXAMPLE.org/"
+		if _, err := store.Links.Patch(link.ID, LinkPatch{Link: &url}); !errors.Is(err, ErrDuplicateLink) {
+			t.Errorf("Links.Patch should reject url of another link, but error: %v", err)
+		}
+		if patched, err = store.Links.AllowDuplicate().Patch(link.ID, LinkPatch{Link: &url}); err != nil ||
+			patched.Link != url {
+			t.Errorf("Links.Patch with allowed duplicate should change url, but returns: %v, %v", patched, err)
+		}
+	})
+}
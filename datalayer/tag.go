@@ -0,0 +1,250 @@
+package datalayer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chytilp/links/model"
+)
+
+// maxTagLength is the longest tag name in characters.
+const maxTagLength = 64
+
+var (
+	// ErrInvalidTag is returned when tag name is empty, too long or contains comma.
+	ErrInvalidTag = errors.New("tag name must have 1-64 characters without comma")
+
+	// ErrDuplicateTag is returned when tag is renamed to name of another tag.
+	ErrDuplicateTag = errors.New("tag with the name already exists, merge the tags instead")
+)
+
+// Tags type wrapps database methods above tag table.
+type Tags struct {
+	records              *records
+	viewer               *model.User
+	selectPattern        string
+	groupPattern         string
+	findPattern          string
+	insertPattern        string
+	renamePattern        string
+	deletePattern        string
+	mergeLinksPattern    string
+	deleteLinkTagPattern string
+	insertLinkTagPattern string
+	clearLinkTagsPattern string
+}
+
+// CreateTags creates and returns instance of Tags struct.
+func CreateTags(db *sql.DB) *Tags {
+	tags := &Tags{
+		records: newRecords(db),
+		selectPattern: "SELECT t.id AS t_id, t.name AS t_name, t.created AS t_created, " +
+			"COUNT(vl.link_id) AS link_count " +
+			"FROM tag t " +
+			"LEFT JOIN (SELECT lt.tag_id, lt.link_id FROM link_tag lt " +
+			"JOIN link l on lt.link_id = l.id " +
+			"JOIN category c on l.category_id = c.id " +
+			"WHERE %s) vl on vl.tag_id = t.id ",
+		groupPattern:  " GROUP BY t.id, t.name, t.created",
+		findPattern:   "SELECT id FROM tag WHERE name = ?",
+		insertPattern: "INSERT INTO tag(name) VALUES(?)",
+		renamePattern: "UPDATE tag SET name=? WHERE id=?",
+		deletePattern: "DELETE FROM tag WHERE id=?",
+		mergeLinksPattern: "INSERT INTO link_tag(link_id, tag_id) " +
+			"SELECT s.link_id, ? FROM link_tag s " +
+			"LEFT JOIN link_tag d on d.link_id = s.link_id AND d.tag_id = ? " +
+			"WHERE s.tag_id = ? AND d.id IS NULL",
+		deleteLinkTagPattern: "DELETE FROM link_tag WHERE tag_id=?",
+		insertLinkTagPattern: "INSERT INTO link_tag(link_id, tag_id) VALUES(?, ?)",
+		clearLinkTagsPattern: "DELETE FROM link_tag WHERE link_id=?",
+	}
+	return tags
+}
+
+// ForUser method returns Tags which count only links visible for user,
+// superadmin counts all active links.
+func (t *Tags) ForUser(user *model.User) TagStore {
+	scoped := *t
+	scoped.viewer = user
+	return &scoped
+}
+
+// selectQuery returns select of tags counting active links visible for viewer
+// with its values.
+func (t *Tags) selectQuery() (string, []interface{}) {
+	if t.viewer == nil || t.viewer.Superadmin {
+		return fmt.Sprintf(t.selectPattern, activeLinkPattern), nil
+	}
+	condition := andConditions(activeLinkPattern, visibleLinkPattern)
+	return fmt.Sprintf(t.selectPattern, condition), []interface{}{t.viewer.ID, t.viewer.ID}
+}
+
+// Get method returns tag record from tag table by id with number of its links.
+func (t *Tags) Get(id int) (*model.Tag, error) {
+	query, values := t.selectQuery()
+	row := t.records.db.QueryRow(query+"WHERE t.id = ?"+t.groupPattern, append(values, id)...)
+	tag, err := t.scanRow(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Retrieve method returns all tags with numbers of their active links, most
+// used first.
+func (t *Tags) Retrieve() ([]*model.Tag, error) {
+	query, values := t.selectQuery()
+	rows, err := t.records.db.Query(query+t.groupPattern+" ORDER BY link_count DESC, t_name", values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []*model.Tag{}
+	for rows.Next() {
+		tag, err := t.scanRow(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tag)
+	}
+	return result, rows.Err()
+}
+
+// Rename method changes name of tag by id. Name of another tag fails
+// with ErrDuplicateTag.
+func (t *Tags) Rename(id int, name string) (*model.Tag, error) {
+	name, err := normalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.Get(id); err != nil {
+		return nil, err
+	}
+	var existingID int
+	err = t.records.db.QueryRow(t.findPattern, name).Scan(&existingID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && existingID != id {
+		return nil, ErrDuplicateTag
+	}
+	if err := t.records.update([]interface{}{name, id}, t.renamePattern); err != nil {
+		return nil, err
+	}
+	return t.Get(id)
+}
+
+// Merge method moves links of source tag to target tag and deletes source tag.
+func (t *Tags) Merge(sourceID int, targetID int) (*model.Tag, error) {
+	if _, err := t.Get(sourceID); err != nil {
+		return nil, err
+	}
+	if _, err := t.Get(targetID); err != nil {
+		return nil, err
+	}
+	if sourceID != targetID {
+		err := t.records.inTransaction(func(tx *sql.Tx) error {
+			err := updateWith(tx, []interface{}{targetID, targetID, sourceID}, t.mergeLinksPattern)
+			if err != nil {
+				return err
+			}
+			if err := updateWith(tx, []interface{}{sourceID}, t.deleteLinkTagPattern); err != nil {
+				return err
+			}
+			return updateWith(tx, []interface{}{sourceID}, t.deletePattern)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.Get(targetID)
+}
+
+// setLinkTags replaces tags of link by tags with names, missing tags are created.
+func (t *Tags) setLinkTags(tx *sql.Tx, linkID int, names []string) error {
+	if err := updateWith(tx, []interface{}{linkID}, t.clearLinkTagsPattern); err != nil {
+		return err
+	}
+	for _, name := range names {
+		var tagID int
+		err := tx.QueryRow(t.findPattern, name).Scan(&tagID)
+		if err == sql.ErrNoRows {
+			tagID, err = insertWith(tx, []interface{}{name}, t.insertPattern)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := insertWith(tx, []interface{}{linkID, tagID}, t.insertLinkTagPattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanRow fills tag structure with values from db record.
+func (t *Tags) scanRow(fn scanner) (*model.Tag, error) {
+	tag := &model.Tag{}
+	err := fn(&tag.ID, &tag.Name, &tag.Created, &tag.LinkCount)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// popTagFilter returns copy of filters without tag filters, normalized tags
+// from tag filter and true when links must have all of the tags (tag_match=all).
+func popTagFilter(filters map[string][]string) (map[string][]string, []string, bool, error) {
+	filters, names := popFilter(filters, "tag")
+	filters, match := popFilter(filters, "tag_match")
+	matchAll := false
+	if len(match) > 0 {
+		switch match[0] {
+		case "all":
+			matchAll = true
+		case "any":
+		default:
+			return nil, nil, false, fmt.Errorf("%w: Parameter tag_match must be any or all, value: %s",
+				ErrInvalidFilter, match[0])
+		}
+	}
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("%w: %s", ErrInvalidFilter, err)
+	}
+	return filters, tags, matchAll, nil
+}
+
+// normalizeTag trims and lower cases tag name and checks it is valid.
+func normalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || utf8.RuneCountInString(name) > maxTagLength || strings.Contains(name, ",") {
+		return "", ErrInvalidTag
+	}
+	return name, nil
+}
+
+// normalizeTags normalizes tag names of link, removes duplicates and sorts
+// them. Nil names stay nil, it means tags of link are not changed.
+func normalizeTags(names []string) ([]string, error) {
+	if names == nil {
+		return nil, nil
+	}
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, name := range names {
+		name, err := normalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
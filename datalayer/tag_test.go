@@ -0,0 +1,88 @@
+package datalayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chytilp/links/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func createMockTagGetExpectedQuery(mock sqlmock.Sqlmock, tag *model.Tag) {
+	mock.ExpectQuery("^SELECT (.+) FROM tag t LEFT JOIN \\(SELECT lt.tag_id, lt.link_id FROM link_tag lt (.+) " +
+		"WHERE l.active IS NULL AND c.active IS NULL\\) vl on vl.tag_id = t.id " +
+		"WHERE t.id = \\? GROUP BY t.id, t.name, t.created$").
+		WithArgs(tag.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"t_id", "t_name", "t_created", "link_count"}).
+			AddRow(tag.ID, tag.Name, tag.Created, tag.LinkCount))
+}
+
+func TestTagRenameShouldRejectNameOfAnotherTag(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	created := time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC)
+	tag := &model.Tag{ID: 1, Name: "golang", Created: &created, LinkCount: 2}
+	createMockTagGetExpectedQuery(mock, tag)
+	mock.ExpectQuery("^SELECT id FROM tag WHERE name = \\?$").
+		WithArgs("go").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	tags := CreateTags(db)
+	defer db.Close()
+	if _, err := tags.Rename(1, " Go "); err != ErrDuplicateTag {
+		t.Errorf("Tags.Rename should fail with ErrDuplicateTag, but error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTagMergeShouldMoveLinks(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	created := time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC)
+	source := &model.Tag{ID: 1, Name: "golang", Created: &created, LinkCount: 2}
+	target := &model.Tag{ID: 2, Name: "go", Created: &created, LinkCount: 1}
+	createMockTagGetExpectedQuery(mock, source)
+	createMockTagGetExpectedQuery(mock, target)
+	mock.ExpectBegin()
+	mock.ExpectPrepare("^INSERT INTO link_tag\\(link_id, tag_id\\) SELECT s.link_id, \\? FROM link_tag s (.+) "+
+		"WHERE s.tag_id = \\? AND d.id IS NULL$").
+		ExpectExec().
+		WithArgs(2, 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectPrepare("^DELETE FROM link_tag WHERE tag_id=\\?$").
+		ExpectExec().
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectPrepare("^DELETE FROM tag WHERE id=\\?$").
+		ExpectExec().
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	merged := *target
+	merged.LinkCount = 3
+	createMockTagGetExpectedQuery(mock, &merged)
+	tags := CreateTags(db)
+	defer db.Close()
+	outputTag, err := tags.Merge(1, 2)
+	if err != nil {
+		t.Errorf("Tags.Merge[1, 2] should merge tags, but error: %v", err)
+	}
+	if !cmp.Equal(outputTag, &merged) {
+		t.Errorf("Tag objects are different: %#v, %#v", outputTag, &merged)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestNormalizeTagsShouldCleanNames(t *testing.T) {
+	tags, err := normalizeTags([]string{" Go", "db", "go"})
+	if err != nil || !cmp.Equal(tags, []string{"db", "go"}) {
+		t.Errorf("normalizeTags should return [db go], but returns: %v, %v", tags, err)
+	}
+	for _, names := range [][]string{{""}, {"a,b"}, {string(make([]byte, 65))}} {
+		if _, err := normalizeTags(names); err != ErrInvalidTag {
+			t.Errorf("normalizeTags[%q] should fail with ErrInvalidTag, but error: %v", names, err)
+		}
+	}
+}
@@ -1 +1,401 @@
 package datalayer
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chytilp/links/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrPasswordRequired is returned when new user is saved without password.
+	ErrPasswordRequired = errors.New("password is required")
+
+	// ErrDuplicateRole is returned when the same role is sent more times for one user.
+	ErrDuplicateRole = errors.New("role is assigned more than once")
+
+	// ErrRoleRequired is returned when role of user is sent without id.
+	ErrRoleRequired = errors.New("role id is required")
+
+	// ErrDuplicateEmail is returned when user is saved with email of another user.
+	ErrDuplicateEmail = errors.New("user with the same email already exists")
+
+	// passwordCost is bcrypt cost used for new password hashes, hashes
+	// with lower cost are rehashed after successful verification.
+	passwordCost = bcrypt.DefaultCost
+)
+
+// Users type wrapps database methods above user table.
+type Users struct {
+	records               *records
+	fieldsForSelect       []string
+	selectPattern         string
+	insertPattern         string
+	updatePattern         string
+	passwordPattern       string
+	deletePattern         string
+	rolesPattern          string
+	insertRolePattern     string
+	deleteRolesPattern    string
+	passwordSelectPattern string
+	emailPattern          string
+}
+
+// CreateUsers creates and returns instance of Users struct.
+func CreateUsers(db *sql.DB) *Users {
+	users := &Users{
+		records: newRecords(db),
+		fieldsForSelect: []string{"u_id", "u_name", "email", "superadmin", "u_active",
+			"u_created"},
+		selectPattern: "SELECT u.id AS u_id, u.name AS u_name, u.email, u.superadmin, " +
+			"u.active AS u_active, u.created AS u_created " +
+			"FROM `user` u ",
+		insertPattern: "INSERT INTO `user`(name, email, password, superadmin) " +
+			"VALUES(?, ?, ?, ?)",
+		updatePattern:   "UPDATE `user` SET name=?, email=?, superadmin=? WHERE id=?",
+		passwordPattern: "UPDATE `user` SET password=? WHERE id=?",
+		deletePattern:   "UPDATE `user` SET active=? WHERE id=?",
+		rolesPattern: "SELECT ur.id AS ur_id, r.id AS r_id, r.name AS r_name, " +
+			"r.active AS r_active, r.created AS r_created " +
+			"FROM user_role ur " +
+			"JOIN role r on ur.role_id = r.id " +
+			"WHERE ur.user_id = ?",
+		insertRolePattern:     "INSERT INTO user_role(user_id, role_id) VALUES(?, ?)",
+		deleteRolesPattern:    "DELETE FROM user_role WHERE user_id=?",
+		passwordSelectPattern: "SELECT password FROM `user` WHERE id = ?",
+		emailPattern:          "SELECT id FROM `user` WHERE LOWER(email) = LOWER(?) AND id <> ?",
+	}
+	return users
+}
+
+// Get method returns user record from user table by id together with its roles.
+// Password is never returned.
+func (u *Users) Get(id int) (*model.User, error) {
+	row := u.records.db.QueryRow(u.selectPattern+" WHERE u.id = ?", id)
+	user, err := u.scanRow(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := u.roles(user)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = &roles
+	return user, nil
+}
+
+// FindByEmail method returns user record from user table by email, without roles.
+// Emails are compared case-insensitively.
+func (u *Users) FindByEmail(email string) (*model.User, error) {
+	row := u.records.db.QueryRow(u.selectPattern+" WHERE LOWER(u.email) = LOWER(?)", email)
+	return u.scanRow(row.Scan)
+}
+
+// roles returns roles assigned to user.
+func (u *Users) roles(user *model.User) ([]model.UserRole, error) {
+	rows, err := u.records.db.Query(u.rolesPattern, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []model.UserRole{}
+	for rows.Next() {
+		userRole := model.UserRole{Role: &model.Role{}}
+		err := rows.Scan(&userRole.ID, &userRole.Role.ID, &userRole.Role.Name,
+			&userRole.Role.Active, &userRole.Role.Created)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, userRole)
+	}
+	return roles, rows.Err()
+}
+
+// Save method insert/update record in user table. Password is stored as
+// bcrypt hash and it is changed only when it is set. When user.Roles is not
+// nil, roles of user are replaced by sent roles in the same transaction.
+// Email of another user fails with ErrDuplicateEmail.
+func (u *Users) Save(user model.User) (*model.User, error) {
+	if user.ID == 0 && user.Password == "" {
+		return nil, ErrPasswordRequired
+	}
+	roleIDs, err := roleIDs(user.Roles)
+	if err != nil {
+		return nil, err
+	}
+	var passwordHash string
+	if user.Password != "" {
+		passwordHash, err = hashPassword(user.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+	id := user.ID
+	err = u.records.inTransaction(func(tx *sql.Tx) error {
+		if err := u.checkEmail(tx, user.Email, id); err != nil {
+			return err
+		}
+		var err error
+		if id > 0 {
+			err = u.update(tx, user, passwordHash)
+		} else {
+			id, err = u.insert(tx, user, passwordHash)
+		}
+		if err != nil {
+			return err
+		}
+		if user.Roles == nil {
+			return nil
+		}
+		return u.saveRoles(tx, id, roleIDs)
+	})
+	if isDuplicateKey(err) {
+		return nil, ErrDuplicateEmail
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u.Get(id)
+}
+
+// checkEmail returns ErrDuplicateEmail when user other than user by id has
+// email, emails are compared case-insensitively.
+func (u *Users) checkEmail(tx *sql.Tx, email string, id int) error {
+	var otherID int
+	err := tx.QueryRow(u.emailPattern, email, id).Scan(&otherID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrDuplicateEmail
+}
+
+// roleIDs collects ids of roles sent with user and checks they are unique.
+func roleIDs(userRoles *[]model.UserRole) ([]int, error) {
+	if userRoles == nil {
+		return nil, nil
+	}
+	seen := make(map[int]bool)
+	var ids []int
+	for _, userRole := range *userRoles {
+		if userRole.Role == nil || userRole.Role.ID == 0 {
+			return nil, ErrRoleRequired
+		}
+		if seen[userRole.Role.ID] {
+			return nil, ErrDuplicateRole
+		}
+		seen[userRole.Role.ID] = true
+		ids = append(ids, userRole.Role.ID)
+	}
+	return ids, nil
+}
+
+// insert new record to user table.
+func (u *Users) insert(tx *sql.Tx, user model.User, passwordHash string) (int, error) {
+	values := []interface{}{
+		user.Name,
+		user.Email,
+		passwordHash,
+		user.Superadmin,
+	}
+	id, err := insertWith(tx, values, u.insertPattern)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// update record in user table, password is updated only when passwordHash is set.
+func (u *Users) update(tx *sql.Tx, user model.User, passwordHash string) error {
+	values := []interface{}{
+		user.Name,
+		user.Email,
+		user.Superadmin,
+		user.ID,
+	}
+	err := updateWith(tx, values, u.updatePattern)
+	if err != nil {
+		return err
+	}
+	if passwordHash == "" {
+		return nil
+	}
+	return updateWith(tx, []interface{}{passwordHash, user.ID}, u.passwordPattern)
+}
+
+// saveRoles replaces roles of user by roles with given ids.
+func (u *Users) saveRoles(tx *sql.Tx, userID int, roleIDs []int) error {
+	err := updateWith(tx, []interface{}{userID}, u.deleteRolesPattern)
+	if err != nil {
+		return err
+	}
+	for _, roleID := range roleIDs {
+		_, err := insertWith(tx, []interface{}{userID, roleID}, u.insertRolePattern)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete method archives record in user table by id.
+func (u *Users) Delete(id int, time time.Time) (*model.User, error) {
+	values := []interface{}{
+		time,
+		id,
+	}
+	err := u.records.update(values, u.deletePattern)
+	if err != nil {
+		return nil, err
+	}
+	return u.Get(id)
+}
+
+// Retrieve method selects from user table records by sended filers.
+// Roles of users are not loaded.
+func (u *Users) Retrieve(filters map[string][]string) ([]*model.User, error) {
+	whereClause, values, err := u.buildFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	var rows *sql.Rows
+	if len(whereClause) != 0 {
+		query := u.selectPattern + "WHERE " + whereClause
+		rows, err = u.records.db.Query(query, values...)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rows, err = u.records.db.Query(u.selectPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+	var result []*model.User
+	for rows.Next() {
+		user, err := u.scanRow(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, user)
+	}
+	return result, rows.Err()
+}
+
+// VerifyPassword method checks password of user by id. Password hashes
+// created with lower cost and passwords stored before hashing was
+// introduced are transparently replaced by new hash on success.
+func (u *Users) VerifyPassword(id int, password string) (bool, error) {
+	var stored string
+	row := u.records.db.QueryRow(u.passwordSelectPattern, id)
+	if err := row.Scan(&stored); err != nil {
+		return false, err
+	}
+	ok, rehash, err := verifyPassword(stored, password)
+	if err != nil || !ok {
+		return false, err
+	}
+	if rehash {
+		return true, u.rehashPassword(id, password)
+	}
+	return true, nil
+}
+
+// verifyPassword checks password against stored hash (or plain password
+// stored before hashing was introduced) and reports if it should be rehashed.
+func verifyPassword(stored string, password string) (bool, bool, error) {
+	if !isPasswordHash(stored) {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+			return false, false, nil
+		}
+		return true, true, nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if cost, err := bcrypt.Cost([]byte(stored)); err == nil && cost < passwordCost {
+		return true, true, nil
+	}
+	return true, false, nil
+}
+
+// rehashPassword stores new hash of password for user by id.
+func (u *Users) rehashPassword(id int, password string) error {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return u.records.update([]interface{}{passwordHash, id}, u.passwordPattern)
+}
+
+// hashPassword returns bcrypt hash of password.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// isPasswordHash checks if stored password is bcrypt hash.
+func isPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") ||
+		strings.HasPrefix(stored, "$2y$")
+}
+
+// buildFilters creates sql filter and prepare values from request input.
+func (u *Users) buildFilters(filters map[string][]string) (string, []interface{}, error) {
+	return buildFilters(filters, u.fieldsForSelect, u.convertValue)
+}
+
+// scanRow fills user structure with values from db record.
+func (u *Users) scanRow(fn scanner) (*model.User, error) {
+	user := &model.User{}
+	err := fn(&user.ID, &user.Name, &user.Email, &user.Superadmin, &user.Active,
+		&user.Created)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// convertValues converts value from request to correct type.
+func (u *Users) convertValue(field string, value string) (interface{}, error) {
+	switch field {
+	case "u_id":
+		intVal, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		return intVal, nil
+	case "u_name", "email":
+		return value, nil
+	case "superadmin":
+		boolVal, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		return boolVal, nil
+	case "u_active", "u_created":
+		// 2014-11-12T11:45:26.371Z
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("Unknown field %s", field)
+}
@@ -0,0 +1,106 @@
+package datalayer
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/chytilp/links/model"
+)
+
+// ErrDuplicateUserRole is returned when role is already assigned to user.
+var ErrDuplicateUserRole = errors.New("role is already assigned to user")
+
+// UserRoles type wrapps database methods above user_role table.
+type UserRoles struct {
+	records       *records
+	selectPattern string
+	insertPattern string
+	updatePattern string
+	deletePattern string
+}
+
+// CreateUserRoles creates and returns instance of UserRoles struct.
+func CreateUserRoles(db *sql.DB) *UserRoles {
+	userRoles := &UserRoles{
+		records: newRecords(db),
+		selectPattern: "SELECT ur.id AS ur_id, u.id AS u_id, u.name AS u_name, u.email, " +
+			"u.superadmin, u.active AS u_active, u.created AS u_created, " +
+			"r.id AS r_id, r.name AS r_name, r.active AS r_active, r.created AS r_created " +
+			"FROM user_role ur " +
+			"JOIN `user` u on ur.user_id = u.id " +
+			"JOIN role r on ur.role_id = r.id ",
+		insertPattern: "INSERT INTO user_role(user_id, role_id) VALUES(?, ?)",
+		updatePattern: "UPDATE user_role SET user_id=?, role_id=? WHERE id=?",
+		deletePattern: "DELETE FROM user_role WHERE id=?",
+	}
+	return userRoles
+}
+
+// Get method returns user_role record by id with its user and role.
+func (u *UserRoles) Get(id int) (*model.UserRole, error) {
+	row := u.records.db.QueryRow(u.selectPattern+" WHERE ur.id = ?", id)
+	userRole, err := u.scanRow(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	return userRole, nil
+}
+
+// Find method returns user_role record by user id and role id.
+func (u *UserRoles) Find(userID int, roleID int) (*model.UserRole, error) {
+	row := u.records.db.QueryRow(u.selectPattern+" WHERE ur.user_id = ? AND ur.role_id = ?",
+		userID, roleID)
+	userRole, err := u.scanRow(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	return userRole, nil
+}
+
+// Save method insert/update record in user_role table. Assigning role which
+// user already has in another record fails with ErrDuplicateUserRole, also
+// when the record is inserted concurrently after the check.
+func (u *UserRoles) Save(userRole model.UserRole) (*model.UserRole, error) {
+	existing, err := u.Find(userRole.User.ID, userRole.Role.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && existing.ID != userRole.ID {
+		return nil, ErrDuplicateUserRole
+	}
+	id := userRole.ID
+	values := []interface{}{
+		userRole.User.ID,
+		userRole.Role.ID,
+	}
+	if id > 0 {
+		err = u.records.update(append(values, id), u.updatePattern)
+	} else {
+		id, err = u.records.insert(values, u.insertPattern)
+	}
+	if isDuplicateKey(err) {
+		return nil, ErrDuplicateUserRole
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u.Get(id)
+}
+
+// Delete method removes record from user_role table by id.
+func (u *UserRoles) Delete(id int) error {
+	return u.records.update([]interface{}{id}, u.deletePattern)
+}
+
+// scanRow fills user_role structure with values from db record.
+func (u *UserRoles) scanRow(fn scanner) (*model.UserRole, error) {
+	userRole := &model.UserRole{User: &model.User{}, Role: &model.Role{}}
+	user := userRole.User
+	role := userRole.Role
+	err := fn(&userRole.ID, &user.ID, &user.Name, &user.Email, &user.Superadmin,
+		&user.Active, &user.Created, &role.ID, &role.Name, &role.Active, &role.Created)
+	if err != nil {
+		return nil, err
+	}
+	return userRole, nil
+}
@@ -0,0 +1,113 @@
+package datalayer
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chytilp/links/model"
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/go-cmp/cmp"
+)
+
+func createUserRole(id int, userID int, roleID int) *model.UserRole {
+	user := createUser(userID, "petr")
+	user.Roles = nil
+	return &model.UserRole{
+		ID:   id,
+		User: user,
+		Role: createRole(roleID, "admin"),
+	}
+}
+
+func createMockUserRoleExpectedQuery(mock sqlmock.Sqlmock, query string, userRole *model.UserRole,
+	args ...driver.Value) {
+	columns := []string{"ur_id", "u_id", "u_name", "email", "superadmin", "u_active", "u_created",
+		"r_id", "r_name", "r_active", "r_created"}
+	rows := sqlmock.NewRows(columns)
+	if userRole != nil {
+		user := userRole.User
+		role := userRole.Role
+		rows.AddRow(userRole.ID, user.ID, user.Name, user.Email, user.Superadmin, user.Active,
+			user.Created, role.ID, role.Name, role.Active, role.Created)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM user_role ur JOIN `user` u on ur.user_id = u.id " +
+		"JOIN role r on ur.role_id = r.id " + query).
+		WithArgs(args...).
+		WillReturnRows(rows)
+}
+
+func TestUserRoleSaveShouldInsertRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	expectedUserRole := createUserRole(id, 1, 2)
+	createMockUserRoleExpectedQuery(mock, "WHERE ur.user_id = \\? AND ur.role_id = \\?", nil, 1, 2)
+	mock.ExpectPrepare("^INSERT INTO user_role\\(user_id, role_id\\) VALUES\\(\\?, \\?\\)").
+		ExpectExec().
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(int64(id), 1))
+	createMockUserRoleExpectedQuery(mock, "WHERE ur.id = \\?", expectedUserRole, id)
+	userRoles := CreateUserRoles(db)
+	defer db.Close()
+	outputUserRole, err := userRoles.Save(*createUserRole(0, 1, 2))
+	if err != nil {
+		t.Errorf("UserRoles.Save should insert record, but error: %v", err)
+	}
+	same := cmp.Equal(outputUserRole, expectedUserRole)
+	if !same {
+		t.Errorf("UserRole object are different: %#v, %#v", outputUserRole, expectedUserRole)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserRoleSaveShouldRejectDuplicatePair(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	createMockUserRoleExpectedQuery(mock, "WHERE ur.user_id = \\? AND ur.role_id = \\?",
+		createUserRole(3, 1, 2), 1, 2)
+	userRoles := CreateUserRoles(db)
+	defer db.Close()
+	_, err := userRoles.Save(*createUserRole(0, 1, 2))
+	if err != ErrDuplicateUserRole {
+		t.Errorf("UserRoles.Save should reject duplicate pair, but error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserRoleSaveShouldRejectConcurrentDuplicatePair(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	createMockUserRoleExpectedQuery(mock, "WHERE ur.user_id = \\? AND ur.role_id = \\?", nil, 1, 2)
+	mock.ExpectPrepare("^INSERT INTO user_role\\(user_id, role_id\\) VALUES\\(\\?, \\?\\)").
+		ExpectExec().
+		WithArgs(1, 2).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-2' for key 'uq_user_role'"})
+	userRoles := CreateUserRoles(db)
+	defer db.Close()
+	_, err := userRoles.Save(*createUserRole(0, 1, 2))
+	if err != ErrDuplicateUserRole {
+		t.Errorf("UserRoles.Save should reject pair inserted concurrently, but error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserRoleDeleteShouldRemoveRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	mock.ExpectPrepare("^DELETE FROM user_role WHERE id=\\?").
+		ExpectExec().
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	userRoles := CreateUserRoles(db)
+	defer db.Close()
+	err := userRoles.Delete(1)
+	if err != nil {
+		t.Errorf("UserRoles.Delete[%d] should remove record, but error: %v", 1, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1 +1,329 @@
 package datalayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chytilp/links/model"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	// keep hashing in tests fast
+	passwordCost = bcrypt.MinCost
+}
+
+func createUser(id int, name string, roles ...*model.Role) *model.User {
+	created := time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC)
+	userRoles := []model.UserRole{}
+	for index, role := range roles {
+		userRoles = append(userRoles, model.UserRole{ID: index + 1, Role: role})
+	}
+	expectedUser := &model.User{
+		ID:         id,
+		Name:       name,
+		Email:      name + "@links.cz",
+		Superadmin: false,
+		Active:     nil,
+		Created:    &created,
+		Roles:      &userRoles,
+	}
+	return expectedUser
+}
+
+func createMockUserGetExpectedQuery(mock sqlmock.Sqlmock, user *model.User, id int) {
+	columns := []string{"u_id", "u_name", "email", "superadmin", "u_active", "u_created"}
+	mock.ExpectQuery("^SELECT (.+) FROM `user` u WHERE u.id = ?").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			user.ID, user.Name, user.Email, user.Superadmin, user.Active, user.Created))
+	roleColumns := []string{"ur_id", "r_id", "r_name", "r_active", "r_created"}
+	roleRows := sqlmock.NewRows(roleColumns)
+	for _, userRole := range *user.Roles {
+		roleRows.AddRow(userRole.ID, userRole.Role.ID, userRole.Role.Name,
+			userRole.Role.Active, userRole.Role.Created)
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM user_role ur JOIN role r on ur.role_id = r.id WHERE ur.user_id = ?").
+		WithArgs(id).
+		WillReturnRows(roleRows)
+}
+
+func createMockUserEmailExpectedQuery(mock sqlmock.Sqlmock, user *model.User) {
+	mock.ExpectQuery("^SELECT id FROM `user` WHERE LOWER\\(email\\) = LOWER\\(\\?\\) AND id <> \\?").
+		WithArgs(user.Email, user.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func createMockUserRolesExpectedQuery(mock sqlmock.Sqlmock, userID int, roleIDs ...int) {
+	mock.ExpectPrepare("^DELETE FROM user_role WHERE user_id=\\?").
+		ExpectExec().
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for index, roleID := range roleIDs {
+		mock.ExpectPrepare("^INSERT INTO user_role\\(user_id, role_id\\) VALUES\\(\\?, \\?\\)").
+			ExpectExec().
+			WithArgs(userID, roleID).
+			WillReturnResult(sqlmock.NewResult(int64(index+1), 1))
+	}
+}
+
+func TestUserGetShouldReturnRecordWithRoles(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	expectedUser := createUser(id, "petr", createRole(1, "admin"), createRole(2, "editor"))
+	createMockUserGetExpectedQuery(mock, expectedUser, id)
+	users := CreateUsers(db)
+	defer db.Close()
+	user, err := users.Get(id)
+	if err != nil {
+		t.Errorf("Users.Get[%d] should return result, but error: %v", id, err)
+	}
+	same := cmp.Equal(expectedUser, user)
+	if !same {
+		t.Errorf("User object are different: %#v, %#v", expectedUser, user)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserSaveShouldInsertRecordWithRolesInTransaction(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	user := createUser(0, "petr", createRole(2, "editor"))
+	user.Password = "secret"
+	mock.ExpectBegin()
+	createMockUserEmailExpectedQuery(mock, user)
+	mock.ExpectPrepare("^INSERT INTO `user`\\(name, email, password, superadmin\\) VALUES\\(\\?, \\?, \\?, \\?\\)").
+		ExpectExec().
+		WithArgs(user.Name, user.Email, sqlmock.AnyArg(), user.Superadmin).
+		WillReturnResult(sqlmock.NewResult(int64(id), 1))
+	createMockUserRolesExpectedQuery(mock, id, 2)
+	mock.ExpectCommit()
+	expectedUser := createUser(id, "petr", createRole(2, "editor"))
+	createMockUserGetExpectedQuery(mock, expectedUser, id)
+	users := CreateUsers(db)
+	defer db.Close()
+	outputUser, err := users.Save(*user)
+	if err != nil {
+		t.Errorf("Users.Save[%#v] should insert record, but error: %v", user, err)
+	}
+	same := cmp.Equal(outputUser, expectedUser)
+	if !same {
+		t.Errorf("User object are different: %#v, %#v", outputUser, expectedUser)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserSaveShouldUpdateRecordWithoutPassword(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	user := createUser(id, "petr")
+	user.Roles = nil
+	mock.ExpectBegin()
+	createMockUserEmailExpectedQuery(mock, user)
+	mock.ExpectPrepare("^UPDATE `user` SET name=\\?, email=\\?, superadmin=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(user.Name, user.Email, user.Superadmin, user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectedUser := createUser(id, "petr", createRole(1, "admin"))
+	createMockUserGetExpectedQuery(mock, expectedUser, id)
+	users := CreateUsers(db)
+	defer db.Close()
+	outputUser, err := users.Save(*user)
+	if err != nil {
+		t.Errorf("Users.Save[%#v] should update record, but error: %v", user, err)
+	}
+	same := cmp.Equal(outputUser, expectedUser)
+	if !same {
+		t.Errorf("User object are different: %#v, %#v", outputUser, expectedUser)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserSaveShouldRollbackOnRoleError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	user := createUser(id, "petr", createRole(7, "missing"))
+	mock.ExpectBegin()
+	createMockUserEmailExpectedQuery(mock, user)
+	mock.ExpectPrepare("^UPDATE `user` SET name=\\?, email=\\?, superadmin=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(user.Name, user.Email, user.Superadmin, user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("^DELETE FROM user_role WHERE user_id=\\?").
+		ExpectExec().
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("^INSERT INTO user_role\\(user_id, role_id\\) VALUES\\(\\?, \\?\\)").
+		ExpectExec().
+		WithArgs(id, 7).
+		WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectRollback()
+	users := CreateUsers(db)
+	defer db.Close()
+	_, err := users.Save(*user)
+	if err == nil {
+		t.Errorf("Users.Save[%#v] should fail on role error", user)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserSaveShouldRejectDuplicateEmail(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	user := createUser(0, "petr")
+	user.Password = "secret"
+	user.Email = "Petr@Links.cz"
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT id FROM `user` WHERE LOWER\\(email\\) = LOWER\\(\\?\\) AND id <> \\?").
+		WithArgs(user.Email, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectRollback()
+	users := CreateUsers(db)
+	defer db.Close()
+	_, err := users.Save(*user)
+	if err != ErrDuplicateEmail {
+		t.Errorf("Users.Save[%#v] should reject duplicate email, but error: %v", user, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserSaveShouldRejectDuplicateRoles(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	user := createUser(0, "petr", createRole(2, "editor"), createRole(2, "editor"))
+	user.Password = "secret"
+	users := CreateUsers(db)
+	defer db.Close()
+	_, err := users.Save(*user)
+	if err != ErrDuplicateRole {
+		t.Errorf("Users.Save[%#v] should reject duplicate roles, but error: %v", user, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserSaveShouldRejectRoleWithoutID(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	user := createUser(0, "petr", nil)
+	user.Password = "secret"
+	users := CreateUsers(db)
+	defer db.Close()
+	_, err := users.Save(*user)
+	if err != ErrRoleRequired {
+		t.Errorf("Users.Save[%#v] should reject role without id, but error: %v", user, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserDeleteShouldArchiveRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	id := 1
+	user := createUser(id, "petr")
+	now := time.Now()
+	user.Active = &now
+	mock.ExpectPrepare("^UPDATE `user` SET active=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(now, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	createMockUserGetExpectedQuery(mock, user, id)
+	users := CreateUsers(db)
+	defer db.Close()
+	outputUser, err := users.Delete(id, now)
+	if err != nil {
+		t.Errorf("Users.Delete[%d, %s] should archive record, but error: %v",
+			id, now.Format("2006-01-02 15:04:05"), err)
+	}
+	same := cmp.Equal(outputUser, user)
+	if !same {
+		t.Errorf("User object are different: %#v, %#v", outputUser, user)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserRetriveShouldReturnRecords(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	expectedUsers := []*model.User{createUser(1, "petr"), createUser(2, "jana")}
+	columns := []string{"u_id", "u_name", "email", "superadmin", "u_active", "u_created"}
+	rows := sqlmock.NewRows(columns)
+	for _, user := range expectedUsers {
+		rows.AddRow(user.ID, user.Name, user.Email, user.Superadmin, user.Active, user.Created)
+		user.Roles = nil
+	}
+	mock.ExpectQuery("^SELECT (.+) FROM `user` u WHERE superadmin = \\?").
+		WithArgs(false).
+		WillReturnRows(rows)
+	users := CreateUsers(db)
+	defer db.Close()
+	filters := make(map[string][]string)
+	filters["superadmin"] = []string{"false"}
+	outputUsers, err := users.Retrieve(filters)
+	if err != nil {
+		t.Errorf("Users.Retrieve[%v] should retrieve records, but error: %v", filters, err)
+	}
+	same := cmp.Equal(outputUsers, expectedUsers)
+	if !same {
+		t.Errorf("User object are different: %#v, %#v", outputUsers, expectedUsers)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserVerifyPasswordShouldCheckHash(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	hash, _ := hashPassword("secret")
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("^SELECT password FROM `user` WHERE id = ?").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hash))
+	}
+	users := CreateUsers(db)
+	defer db.Close()
+	ok, err := users.VerifyPassword(1, "secret")
+	if err != nil || !ok {
+		t.Errorf("Users.VerifyPassword should accept correct password, but: %t, %v", ok, err)
+	}
+	ok, err = users.VerifyPassword(1, "wrong")
+	if err != nil || ok {
+		t.Errorf("Users.VerifyPassword should reject wrong password, but: %t, %v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserVerifyPasswordShouldUpgradePlainPassword(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	mock.ExpectQuery("^SELECT password FROM `user` WHERE id = ?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("secret"))
+	mock.ExpectPrepare("^UPDATE `user` SET password=\\? WHERE id=\\?").
+		ExpectExec().
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	users := CreateUsers(db)
+	defer db.Close()
+	ok, err := users.VerifyPassword(1, "secret")
+	if err != nil || !ok {
+		t.Errorf("Users.VerifyPassword should accept plain password, but: %t, %v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1,10 +1,12 @@
 module github.com/chytilp/links
 
-go 1.15
+go 1.16
 
 require (
-    github.com/BurntSushi/toml
-    github.com/go-sql-driver/mysql
-    github.com/DATA-DOG/go-sqlmock
-    github.com/google/go-cmp
+	github.com/BurntSushi/toml v0.4.0
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/google/go-cmp v0.6.0
+	github.com/mattn/go-sqlite3 v1.14.17
+	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b
 )
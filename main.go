@@ -1,19 +1,48 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"os"
 
+	"github.com/chytilp/links/config"
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/logging"
 	"github.com/chytilp/links/rest"
 )
 
 func main() {
-	server := http.Server{
-		Addr: "127.0.0.1:9073",
+	db, err := datalayer.Open(config.App.Database)
+	if err != nil {
+		logging.L.Error("Error from opening database. err: %s", err)
+		os.Exit(1)
 	}
+	defer db.Close()
 
-	http.Handle("/link/", &rest.LinkHandler{})
-	http.Handle("/category/", &rest.CategoryHandler{})
-	http.Handle("/user/", &rest.UserHandler{})
-	http.Handle("/role/", &rest.RoleHandler{})
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(db, config.App.Database.GetDriver(), os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := admin(datalayer.CreateStore(db).Users, os.Args[2:], os.Stdin); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if config.App.Migrations.AutoApply {
+		if err := autoMigrate(db, config.App.Database.GetDriver()); err != nil {
+			logging.L.Error("Error from applying migrations. err: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	server := http.Server{
+		Addr:    "127.0.0.1:9073",
+		Handler: rest.NewHandler(datalayer.CreateStore(db)),
+	}
 	server.ListenAndServe()
 }
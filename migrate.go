@@ -0,0 +1,67 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/chytilp/links/logging"
+	"github.com/chytilp/links/migrations"
+)
+
+// migrate runs migrate command with action up, down or status.
+func migrate(db *sql.DB, driver string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: links migrate up|down|status")
+	}
+	migrator, err := migrations.CreateMigrator(db, driver)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migration")
+		}
+	case "down":
+		migration, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d_%s\n", migration.Version, migration.Name)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied != nil {
+				applied = "applied " + status.Applied.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	default:
+		return fmt.Errorf("Unknown migrate action %s, use up, down or status", args[0])
+	}
+	return nil
+}
+
+// autoMigrate applies pending migrations on server startup.
+func autoMigrate(db *sql.DB, driver string) error {
+	migrator, err := migrations.CreateMigrator(db, driver)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up()
+	for _, migration := range applied {
+		logging.L.Info("Applied migration %d_%s\n", migration.Version, migration.Name)
+	}
+	return err
+}
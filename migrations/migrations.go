@@ -0,0 +1,230 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*/*.sql
+var files embed.FS
+
+// ErrNoMigration is returned by Down when there is no applied migration.
+var ErrNoMigration = errors.New("no applied migration")
+
+// Migration type represents one versioned schema change with its up and
+// down sql scripts.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Status type represents migration together with time it was applied.
+// Applied is nil for pending migrations.
+type Status struct {
+	Migration
+	Applied *time.Time
+}
+
+// Migrator type applies and rolls back migrations in db. Applied versions
+// are tracked in schema_migrations table.
+type Migrator struct {
+	db                 *sql.DB
+	migrations         []Migration
+	createTablePattern string
+	selectPattern      string
+	insertPattern      string
+	deletePattern      string
+}
+
+// CreateMigrator creates and returns instance of Migrator struct with
+// migrations embedded in binary for database driver (mysql or sqlite).
+func CreateMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	migrations, err := load(files, "sql/"+driver)
+	if err != nil {
+		return nil, fmt.Errorf("No migrations for database driver %s: %s", driver, err)
+	}
+	migrator := &Migrator{
+		db:         db,
+		migrations: migrations,
+		createTablePattern: "CREATE TABLE IF NOT EXISTS schema_migrations (" +
+			"version INT NOT NULL PRIMARY KEY, " +
+			"name VARCHAR(255) NOT NULL, " +
+			"applied DATETIME NOT NULL)",
+		selectPattern: "SELECT version, applied FROM schema_migrations ORDER BY version",
+		insertPattern: "INSERT INTO schema_migrations(version, name, applied) VALUES(?, ?, ?)",
+		deletePattern: "DELETE FROM schema_migrations WHERE version=?",
+	}
+	return migrator, nil
+}
+
+// load reads migrations from files named <version>_<name>.(up|down).sql
+// and returns them sorted by version.
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		parts := strings.SplitN(strings.TrimSuffix(entry.Name(), ".sql"), "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Wrong migration file name %s", entry.Name())
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("Wrong migration file name %s", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version}
+			byVersion[version] = migration
+		}
+		switch {
+		case strings.HasSuffix(parts[1], ".up"):
+			migration.Name = strings.TrimSuffix(parts[1], ".up")
+			migration.up = string(content)
+		case strings.HasSuffix(parts[1], ".down"):
+			migration.Name = strings.TrimSuffix(parts[1], ".down")
+			migration.down = string(content)
+		default:
+			return nil, fmt.Errorf("Wrong migration file name %s", entry.Name())
+		}
+	}
+	result := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("Migration %d must have both up and down file", migration.Version)
+		}
+		result = append(result, *migration)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// Up method applies all pending migrations in order of versions and returns
+// applied ones.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var result []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.run(migration.up, m.insertPattern, migration.Version, migration.Name, time.Now())
+		if err != nil {
+			return result, fmt.Errorf("Migration %d_%s failed: %s", migration.Version, migration.Name, err)
+		}
+		result = append(result, migration)
+	}
+	return result, nil
+}
+
+// Down method rolls back the last applied migration and returns it.
+func (m *Migrator) Down() (*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.run(migration.down, m.deletePattern, migration.Version)
+		if err != nil {
+			return nil, fmt.Errorf("Migration %d_%s failed: %s", migration.Version, migration.Name, err)
+		}
+		return &migration, nil
+	}
+	return nil, ErrNoMigration
+}
+
+// Status method returns all known migrations with time of their application.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if t, ok := applied[migration.Version]; ok {
+			status.Applied = &t
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// applied returns versions of applied migrations with time of application.
+// schema_migrations table is created when it does not exist.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if _, err := m.db.Exec(m.createTablePattern); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query(m.selectPattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var applied time.Time
+		if err := rows.Scan(&version, &applied); err != nil {
+			return nil, err
+		}
+		result[version] = applied
+	}
+	return result, rows.Err()
+}
+
+// run executes statements of migration script and records change in
+// schema_migrations table in one transaction. Note that MySQL commits DDL
+// statements implicitly, so failed migration may be applied partially.
+func (m *Migrator) run(script string, expression string, values ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements(script) {
+		if _, err = tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err = tx.Exec(expression, values...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// statements splits sql script to single statements, because db driver
+// does not execute more statements at once.
+func statements(script string) []string {
+	var result []string
+	for _, statement := range strings.Split(script, ";") {
+		statement = strings.TrimSpace(statement)
+		if statement != "" {
+			result = append(result, statement)
+		}
+	}
+	return result
+}
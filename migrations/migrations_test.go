@@ -0,0 +1,137 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func createMockAppliedQuery(mock sqlmock.Sqlmock, versions ...int) {
+	mock.ExpectExec("^CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied"})
+	for _, version := range versions {
+		rows.AddRow(version, time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC))
+	}
+	mock.ExpectQuery("^SELECT version, applied FROM schema_migrations ORDER BY version").
+		WillReturnRows(rows)
+}
+
+func TestLoadShouldReturnEmbeddedMigrationsInOrder(t *testing.T) {
+	for _, driver := range []string{"mysql", "sqlite"} {
+		migrations, err := load(files, "sql/"+driver)
+		if err != nil {
+			t.Errorf("load[%s] should return migrations, but error: %v", driver, err)
+		}
+		if len(migrations) == 0 {
+			t.Errorf("load[%s] should return embedded migrations", driver)
+		}
+		for i, migration := range migrations {
+			if migration.Version != i+1 {
+				t.Errorf("Migration[%s][%d] should have version %d, but has %d", driver, i, i+1,
+					migration.Version)
+			}
+			if migration.up == "" || migration.down == "" {
+				t.Errorf("Migration[%s][%d] should have up and down script", driver, i)
+			}
+		}
+	}
+}
+
+func TestLoadShouldRejectMissingDownFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0001_first.up.sql": {Data: []byte("CREATE TABLE a (id INT)")},
+	}
+	if _, err := load(fsys, "sql"); err == nil {
+		t.Errorf("load should reject migration without down file")
+	}
+}
+
+func TestUpShouldApplyPendingMigrations(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	migrator, err := CreateMigrator(db, "mysql")
+	if err != nil {
+		t.Fatalf("CreateMigrator should return migrator, but error: %v", err)
+	}
+	migrator.migrations, err = load(fstest.MapFS{
+		"sql/0001_first.up.sql":    {Data: []byte("CREATE TABLE a (id INT);")},
+		"sql/0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"sql/0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT);\nCREATE TABLE c (id INT);")},
+		"sql/0002_second.down.sql": {Data: []byte("DROP TABLE c;\nDROP TABLE b;")},
+	}, "sql")
+	if err != nil {
+		t.Fatalf("load should return migrations, but error: %v", err)
+	}
+	createMockAppliedQuery(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("^CREATE TABLE b \\(id INT\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^CREATE TABLE c \\(id INT\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO schema_migrations\\(version, name, applied\\) VALUES\\(\\?, \\?, \\?\\)").
+		WithArgs(2, "second", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Errorf("Migrator.Up should apply migrations, but error: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("Migrator.Up should apply only migration 2, but applied: %v", applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDownShouldRollBackLastMigration(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	migrator, err := CreateMigrator(db, "mysql")
+	if err != nil {
+		t.Fatalf("CreateMigrator should return migrator, but error: %v", err)
+	}
+	migrator.migrations, err = load(fstest.MapFS{
+		"sql/0001_first.up.sql":    {Data: []byte("CREATE TABLE a (id INT);")},
+		"sql/0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"sql/0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"sql/0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+	}, "sql")
+	if err != nil {
+		t.Fatalf("load should return migrations, but error: %v", err)
+	}
+	createMockAppliedQuery(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec("^DROP TABLE b$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM schema_migrations WHERE version=\\?").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	migration, err := migrator.Down()
+	if err != nil {
+		t.Errorf("Migrator.Down should roll back migration, but error: %v", err)
+	}
+	if migration == nil || migration.Version != 2 {
+		t.Errorf("Migrator.Down should roll back migration 2, but rolled back: %v", migration)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDownShouldFailWithoutAppliedMigration(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	migrator, err := CreateMigrator(db, "mysql")
+	if err != nil {
+		t.Fatalf("CreateMigrator should return migrator, but error: %v", err)
+	}
+	createMockAppliedQuery(mock)
+	if _, err := migrator.Down(); err != ErrNoMigration {
+		t.Errorf("Migrator.Down should fail with ErrNoMigration, but error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
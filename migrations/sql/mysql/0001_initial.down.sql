@@ -0,0 +1,7 @@
+DROP TABLE role_link;
+DROP TABLE user_link;
+DROP TABLE user_role;
+DROP TABLE role;
+DROP TABLE `user`;
+DROP TABLE link;
+DROP TABLE category;
@@ -0,0 +1,70 @@
+CREATE TABLE category (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    parent_id INT NOT NULL DEFAULT 0,
+    active DATETIME NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE link (
+    id INT NOT NULL AUTO_INCREMENT,
+    link VARCHAR(2048) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    category_id INT NOT NULL,
+    active DATETIME NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_link_category FOREIGN KEY (category_id) REFERENCES category (id)
+);
+
+CREATE TABLE `user` (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    superadmin BOOLEAN NOT NULL DEFAULT FALSE,
+    active DATETIME NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_user_email (email)
+);
+
+CREATE TABLE role (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    active DATETIME NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE user_role (
+    id INT NOT NULL AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    role_id INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_user_role (user_id, role_id),
+    CONSTRAINT fk_user_role_user FOREIGN KEY (user_id) REFERENCES `user` (id),
+    CONSTRAINT fk_user_role_role FOREIGN KEY (role_id) REFERENCES role (id)
+);
+
+CREATE TABLE user_link (
+    id INT NOT NULL AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    link_id INT NOT NULL,
+    owner BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_user_link (user_id, link_id),
+    CONSTRAINT fk_user_link_user FOREIGN KEY (user_id) REFERENCES `user` (id),
+    CONSTRAINT fk_user_link_link FOREIGN KEY (link_id) REFERENCES link (id)
+);
+
+CREATE TABLE role_link (
+    id INT NOT NULL AUTO_INCREMENT,
+    role_id INT NOT NULL,
+    link_id INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_role_link (role_id, link_id),
+    CONSTRAINT fk_role_link_role FOREIGN KEY (role_id) REFERENCES role (id),
+    CONSTRAINT fk_role_link_link FOREIGN KEY (link_id) REFERENCES link (id)
+);
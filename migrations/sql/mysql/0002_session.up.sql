@@ -0,0 +1,8 @@
+CREATE TABLE session (
+    token CHAR(64) NOT NULL,
+    user_id INT NOT NULL,
+    expires DATETIME NOT NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (token),
+    CONSTRAINT fk_session_user FOREIGN KEY (user_id) REFERENCES `user` (id)
+);
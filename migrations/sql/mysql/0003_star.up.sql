@@ -0,0 +1,11 @@
+CREATE TABLE star (
+    id INT NOT NULL AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    link_id INT NOT NULL,
+    stars TINYINT NOT NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_star_user_link (user_id, link_id),
+    CONSTRAINT fk_star_user FOREIGN KEY (user_id) REFERENCES `user` (id),
+    CONSTRAINT fk_star_link FOREIGN KEY (link_id) REFERENCES link (id)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE note DROP INDEX ft_note_search;
+
+ALTER TABLE category DROP INDEX ft_category_search;
+
+ALTER TABLE link DROP INDEX ft_link_search;
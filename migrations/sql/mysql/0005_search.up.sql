@@ -0,0 +1,5 @@
+ALTER TABLE link ADD FULLTEXT INDEX ft_link_search (name, link);
+
+ALTER TABLE category ADD FULLTEXT INDEX ft_category_search (name);
+
+ALTER TABLE note ADD FULLTEXT INDEX ft_note_search (note);
@@ -0,0 +1,3 @@
+DROP TABLE link_tag;
+
+DROP TABLE tag;
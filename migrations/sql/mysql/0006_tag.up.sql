@@ -0,0 +1,17 @@
+CREATE TABLE tag (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(64) NOT NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_tag_name (name)
+);
+
+CREATE TABLE link_tag (
+    id INT NOT NULL AUTO_INCREMENT,
+    link_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_link_tag (link_id, tag_id),
+    CONSTRAINT fk_link_tag_link FOREIGN KEY (link_id) REFERENCES link (id),
+    CONSTRAINT fk_link_tag_tag FOREIGN KEY (tag_id) REFERENCES tag (id)
+);
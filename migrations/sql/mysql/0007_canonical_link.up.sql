@@ -0,0 +1,7 @@
+-- hash of canonical url, NULL for links saved as allowed duplicates and links
+-- saved before this migration. Index is not unique, duplicates are checked
+-- only against links visible for user, so other users can save link with the
+-- same canonical url.
+ALTER TABLE link ADD COLUMN canonical_hash CHAR(64) NULL;
+
+ALTER TABLE link ADD INDEX ix_link_canonical (canonical_hash);
@@ -0,0 +1,63 @@
+CREATE TABLE category (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    parent_id INT NOT NULL DEFAULT 0,
+    active DATETIME NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE link (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link VARCHAR(2048) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    category_id INT NOT NULL,
+    active DATETIME NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_link_category FOREIGN KEY (category_id) REFERENCES category (id)
+);
+
+CREATE TABLE `user` (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    superadmin BOOLEAN NOT NULL DEFAULT FALSE,
+    active DATETIME NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_user_email UNIQUE (email)
+);
+
+CREATE TABLE role (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    active DATETIME NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_role (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL,
+    role_id INT NOT NULL,
+    CONSTRAINT uq_user_role UNIQUE (user_id, role_id),
+    CONSTRAINT fk_user_role_user FOREIGN KEY (user_id) REFERENCES `user` (id),
+    CONSTRAINT fk_user_role_role FOREIGN KEY (role_id) REFERENCES role (id)
+);
+
+CREATE TABLE user_link (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL,
+    link_id INT NOT NULL,
+    owner BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT uq_user_link UNIQUE (user_id, link_id),
+    CONSTRAINT fk_user_link_user FOREIGN KEY (user_id) REFERENCES `user` (id),
+    CONSTRAINT fk_user_link_link FOREIGN KEY (link_id) REFERENCES link (id)
+);
+
+CREATE TABLE role_link (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role_id INT NOT NULL,
+    link_id INT NOT NULL,
+    CONSTRAINT uq_role_link UNIQUE (role_id, link_id),
+    CONSTRAINT fk_role_link_role FOREIGN KEY (role_id) REFERENCES role (id),
+    CONSTRAINT fk_role_link_link FOREIGN KEY (link_id) REFERENCES link (id)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE star (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL,
+    link_id INT NOT NULL,
+    stars INTEGER NOT NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_star_user_link UNIQUE (user_id, link_id),
+    CONSTRAINT fk_star_user FOREIGN KEY (user_id) REFERENCES `user` (id),
+    CONSTRAINT fk_star_link FOREIGN KEY (link_id) REFERENCES link (id)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE note (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL,
+    link_id INT NOT NULL,
+    note TEXT NOT NULL,
+    private BOOLEAN NOT NULL DEFAULT FALSE,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_note_user FOREIGN KEY (user_id) REFERENCES `user` (id),
+    CONSTRAINT fk_note_link FOREIGN KEY (link_id) REFERENCES link (id)
+);
@@ -0,0 +1 @@
+DROP INDEX idx_note_link;
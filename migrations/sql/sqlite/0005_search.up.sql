@@ -0,0 +1,2 @@
+-- SQLite has no FULLTEXT indexes, search falls back to LIKE and joins notes by link
+CREATE INDEX idx_note_link ON note (link_id);
@@ -0,0 +1,15 @@
+CREATE TABLE tag (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(64) NOT NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_tag_name UNIQUE (name)
+);
+
+CREATE TABLE link_tag (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    CONSTRAINT uq_link_tag UNIQUE (link_id, tag_id),
+    CONSTRAINT fk_link_tag_link FOREIGN KEY (link_id) REFERENCES link (id),
+    CONSTRAINT fk_link_tag_tag FOREIGN KEY (tag_id) REFERENCES tag (id)
+);
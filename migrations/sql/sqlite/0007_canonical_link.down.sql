@@ -0,0 +1,3 @@
+DROP INDEX ix_link_canonical;
+
+ALTER TABLE link DROP COLUMN canonical_hash;
@@ -13,12 +13,45 @@ type Category struct {
 	Created  *time.Time
 }
 
+// CategoryNode type represents one category with its subcategories in category tree.
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode
+}
+
 // Link type represents one link object saved in db.
 type Link struct {
-	ID       int
-	Link     string
-	Name     string
-	Category *Category
-	Active   *time.Time
-	Created  *time.Time
+	ID          int
+	Link        string
+	Name        string
+	Category    *Category
+	Active      *time.Time
+	Created     *time.Time
+	Rating      float64
+	RatingCount int
+	Tags        []string `json:",omitempty"`
+	Notes       *[]*Note `json:",omitempty"`
+}
+
+// Tag type represents one tag of links saved in db, LinkCount is number of
+// links with the tag.
+type Tag struct {
+	ID        int
+	Name      string
+	Created   *time.Time
+	LinkCount int
+}
+
+// SearchResult type represents one link found by search with its score and
+// highlighted snippets of matching fields.
+type SearchResult struct {
+	Link     *Link
+	Score    float64
+	Snippets []Snippet
+}
+
+// Snippet type represents part of field text with matched words marked by <mark>.
+type Snippet struct {
+	Field string
+	Text  string
 }
@@ -9,7 +9,7 @@ type User struct {
 	ID         int
 	Name       string
 	Email      string
-	Password   string
+	Password   string `json:"-"`
 	Superadmin bool
 	Active     *time.Time
 	Created    *time.Time
@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chytilp/links/datalayer"
+)
+
+// AuthHandler type is type for handling requests to auth endpoint.
+type AuthHandler struct {
+	Users    datalayer.UserStore
+	Sessions datalayer.SessionStore
+}
+
+// credentials are login data sent in request body.
+type credentials struct {
+	Email    string
+	Password string
+}
+
+// Routes registers routes of auth endpoint to router, they do not require session.
+func (h *AuthHandler) Routes(router *Router) {
+	router.HandleFunc("POST", "/auth/login", h.handleLogin)
+	router.HandleFunc("POST", "/auth/logout", h.handleLogout)
+}
+
+func (h *AuthHandler) handleLogin(w http.ResponseWriter, r *http.Request) error {
+	var login credentials
+	if err := decodeBody(r, &login, "credentials"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	user, err := h.Users.FindByEmail(login.Email)
+	if err == sql.ErrNoRows {
+		prepareResponseFromError(w, fmt.Errorf("Wrong email or password"), 401)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ok, err := h.Users.VerifyPassword(user.ID, login.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		prepareResponseFromError(w, fmt.Errorf("Wrong email or password"), 401)
+		return nil
+	}
+	if user.Active != nil {
+		prepareResponseFromError(w, fmt.Errorf("User is deactivated"), 403)
+		return nil
+	}
+	expires := time.Now().Add(sessionLifetime)
+	token, err := h.Sessions.Create(user.ID, expires)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	output, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *AuthHandler) handleLogout(w http.ResponseWriter, r *http.Request) error {
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil {
+		if err = h.Sessions.Delete(cookie.Value); err != nil {
+			return err
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(204)
+	return nil
+}
@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"testing"
+)
+
+func TestAuthEndpointShouldLoginAndLogout(t *testing.T) {
+	server := newTestServer(t)
+	server.createUser("alice", false)
+	response := server.do("POST", "/auth/login", `{"Email": "alice@example.com", "Password": "wrong"}`, nil)
+	server.expectStatus(response, 401, "POST /auth/login")
+
+	response = server.do("POST", "/auth/login", `{"Email": "alice@example.com", "Password": "secret"}`, nil)
+	server.expectStatus(response, 200, "POST /auth/login")
+	cookies := response.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("POST /auth/login should set session cookie, but sets: %v", cookies)
+	}
+	response = server.do("GET", "/category/", "", cookies[0])
+	server.expectStatus(response, 200, "GET /category/")
+
+	response = server.do("POST", "/auth/logout", "", cookies[0])
+	server.expectStatus(response, 204, "POST /auth/logout")
+	response = server.do("GET", "/category/", "", cookies[0])
+	server.expectStatus(response, 401, "GET /category/")
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+// sessionCookie is name of cookie with session token.
+const sessionCookie = "links_session"
+
+// sessionLifetime is how long session is valid after login.
+const sessionLifetime = 24 * time.Hour
+
+// contextKey type is type of keys of values stored by rest in request context.
+type contextKey string
+
+// userKey is request context key of logged in user.
+const userKey contextKey = "user"
+
+// Authenticate wraps handler so it is called only for requests with valid
+// session cookie. Logged in user is available via UserFromContext.
+func Authenticate(sessions datalayer.SessionStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			prepareResponseFromError(w, fmt.Errorf("Authentication required"), 401)
+			return
+		}
+		user, err := sessions.User(cookie.Value, time.Now())
+		if err == sql.ErrNoRows {
+			prepareResponseFromError(w, fmt.Errorf("Session is not valid"), 401)
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), userKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// UserFromContext returns logged in user stored in request context by Authenticate.
+func UserFromContext(ctx context.Context) (*model.User, bool) {
+	user, ok := ctx.Value(userKey).(*model.User)
+	return user, ok
+}
+
+// requireUser returns logged in user from request context, when there is
+// none it writes 401 response.
+func requireUser(w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		prepareResponseFromError(w, fmt.Errorf("Authentication required"), 401)
+	}
+	return user, ok
+}
+
+// requireSuperadmin returns logged in user from request context, when there
+// is none it writes 401 response and when user is not superadmin 403 response.
+func requireSuperadmin(w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !user.Superadmin {
+		prepareResponseFromError(w, fmt.Errorf("Only superadmin can %s %s", r.Method, r.URL.Path), 403)
+		return nil, false
+	}
+	return user, true
+}
@@ -3,8 +3,21 @@ package rest
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
+// pathSegments splits url path to its non empty parts,
+// e.g. /category/5/subtree -> [category 5 subtree].
+func pathSegments(urlPath string) []string {
+	var segments []string
+	for _, segment := range strings.Split(urlPath, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
 func prepareResponseFromMap(w http.ResponseWriter, content map[string]string, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -18,9 +31,7 @@ func prepareResponseFromBytes(w http.ResponseWriter, content []byte, status int)
 	w.Write(content)
 }
 
+// prepareResponseFromError writes err as error response with status, see APIError.
 func prepareResponseFromError(w http.ResponseWriter, err error, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	output, _ := json.Marshal(err)
-	w.Write(output)
+	writeAPIError(w, newAPIError(status, err))
 }
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chytilp/links/config"
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/migrations"
+	"github.com/chytilp/links/model"
+)
+
+// testServer wraps handler of all endpoints above in-memory store.
+type testServer struct {
+	t       *testing.T
+	store   *datalayer.Store
+	handler http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	store := datalayer.CreateMemoryStore()
+	return &testServer{t: t, store: store, handler: NewHandler(store)}
+}
+
+// newSQLiteTestServer creates test server above migrated sqlite database,
+// database is returned for records which have no endpoint, e.g. role_link.
+func newSQLiteTestServer(t *testing.T) (*testServer, *sql.DB) {
+	db, err := datalayer.Open(config.DbConfig{
+		Driver:       config.SQLite,
+		Path:         filepath.Join(t.TempDir(), "links.db"),
+		MaxOpenConns: 1,
+		MaxIdleConns: 1,
+	})
+	if err != nil {
+		t.Fatalf("Open should open sqlite database, but error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migrations.CreateMigrator(db, config.SQLite)
+	if err != nil {
+		t.Fatalf("CreateMigrator should return migrator, but error: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Migrator.Up should create schema, but error: %v", err)
+	}
+	store := datalayer.CreateStore(db)
+	return &testServer{t: t, store: store, handler: NewHandler(store)}, db
+}
+
+// createUser saves user and returns it with session cookie of the user.
+func (s *testServer) createUser(name string, superadmin bool) (*model.User, *http.Cookie) {
+	user, err := s.store.Users.Save(model.User{Name: name, Email: name + "@example.com",
+		Password: "secret", Superadmin: superadmin})
+	if err != nil {
+		s.t.Fatalf("Users.Save should save user %s, but error: %v", name, err)
+	}
+	token, err := s.store.Sessions.Create(user.ID, time.Now().Add(time.Hour))
+	if err != nil {
+		s.t.Fatalf("Sessions.Create should create session, but error: %v", err)
+	}
+	return user, &http.Cookie{Name: sessionCookie, Value: token}
+}
+
+// createCategory saves category and returns it.
+func (s *testServer) createCategory(name string, parentID int) *model.Category {
+	category, err := s.store.Categories.Save(model.Category{Name: name, ParentID: parentID})
+	if err != nil {
+		s.t.Fatalf("Categories.Save should save category %s, but error: %v", name, err)
+	}
+	return category
+}
+
+// createLink saves link owned by user and returns it.
+func (s *testServer) createLink(user *model.User, name string, category *model.Category) *model.Link {
+	link, err := s.store.Links.ForUser(user).Save(model.Link{Link: "https://" + name + ".org",
+		Name: name, Category: category})
+	if err != nil {
+		s.t.Fatalf("Links.Save should save link %s, but error: %v", name, err)
+	}
+	return link
+}
+
+// do sends request to server and returns recorded response.
+func (s *testServer) do(method string, target string, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if cookie != nil {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// expectStatus checks status of response.
+func (s *testServer) expectStatus(response *httptest.ResponseRecorder, status int, request string) {
+	if response.Code != status {
+		s.t.Errorf("%s should return status %d, but returns %d: %s", request, status,
+			response.Code, response.Body.String())
+	}
+}
+
+// decode unmarshals json body of response to out.
+func (s *testServer) decode(response *httptest.ResponseRecorder, out interface{}) {
+	if err := json.Unmarshal(response.Body.Bytes(), out); err != nil {
+		s.t.Fatalf("Response body should be json, but error: %v, body: %s", err, response.Body.String())
+	}
+}
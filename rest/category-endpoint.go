@@ -1,8 +1,201 @@
 package rest
 
-import "net/http"
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
 
 // CategoryHandler type is type for handling requests to category endpoint.
-type CategoryHandler struct{}
+type CategoryHandler struct {
+	Categories datalayer.CategoryStore
+}
+
+// Routes registers routes of category endpoint to router.
+func (h *CategoryHandler) Routes(router *Router) {
+	router.HandleFunc("GET", "/category/", h.handleRetrieve)
+	router.HandleAdmin("POST", "/category/", h.handlePost)
+	router.HandleFunc("GET", "/category/tree", h.handleTree)
+	router.HandleFunc("GET", "/category/{id:int}", h.handleGetOne)
+	router.HandleAdmin("PUT", "/category/{id:int}", h.handlePut)
+	router.HandleAdmin("DELETE", "/category/{id:int}", h.handleDelete)
+	router.HandleFunc("GET", "/category/{id:int}/subtree", h.handleSubtree)
+	router.HandleFunc("GET", "/category/{id:int}/path", h.handlePath)
+}
+
+func (h *CategoryHandler) handleGetOne(w http.ResponseWriter, r *http.Request) error {
+	id := pathInt(r, "id")
+	category, err := h.Categories.Get(id)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Category with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(category)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *CategoryHandler) handleRetrieve(w http.ResponseWriter, r *http.Request) error {
+	queryParams := r.URL.Query()
+	foundCategories, err := h.Categories.Retrieve(queryParams)
+	if err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if foundCategories == nil {
+		foundCategories = []*model.Category{}
+	}
+	output, err := json.Marshal(foundCategories)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *CategoryHandler) handlePost(w http.ResponseWriter, r *http.Request) error {
+	return h.processSave(w, r, 0, 201)
+}
+
+func (h *CategoryHandler) handlePut(w http.ResponseWriter, r *http.Request) error {
+	return h.processSave(w, r, pathInt(r, "id"), 200)
+}
+
+func (h *CategoryHandler) processSave(w http.ResponseWriter, r *http.Request, id int, status int) error {
+	var category model.Category
+	if err := decodeBody(r, &category, "category"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if id > 0 {
+		if _, err := h.Categories.Get(id); err == sql.ErrNoRows {
+			outErr := fmt.Errorf("Category with id=%d was not found. Error: %s", id, err)
+			prepareResponseFromError(w, outErr, 404)
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if err := validateCategory(&category, h.Categories); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	category.ID = id
+	outCategory, err := h.Categories.Save(category)
+	if err == datalayer.ErrCategoryCycle || err == datalayer.ErrParentNotFound {
+		prepareResponseFromError(w, err, 400)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	idmap := make(map[string]int)
+	idmap["id"] = outCategory.ID
+	output, err := json.Marshal(idmap)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, status)
+	return nil
+}
+
+func (h *CategoryHandler) handleDelete(w http.ResponseWriter, r *http.Request) error {
+	id := pathInt(r, "id")
+	_, err := h.Categories.Get(id)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Category with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	now := time.Now()
+	var category *model.Category
+	if r.URL.Query().Get("cascade") == "true" {
+		category, err = h.Categories.DeleteCascade(id, now)
+	} else {
+		var count int
+		count, err = h.Categories.ActiveLinksCount(id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			outErr := fmt.Errorf("Category with id=%d has %d active links, use cascade=true to deactivate them", id, count)
+			prepareResponseFromError(w, outErr, 409)
+			return nil
+		}
+		category, err = h.Categories.Delete(id, now)
+	}
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(category)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *CategoryHandler) handleTree(w http.ResponseWriter, r *http.Request) error {
+	tree, err := h.Categories.Tree()
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *CategoryHandler) handleSubtree(w http.ResponseWriter, r *http.Request) error {
+	id := pathInt(r, "id")
+	subtree, err := h.Categories.Subtree(id)
+	if err == datalayer.ErrNotFound {
+		outErr := fmt.Errorf("Category with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(subtree)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
 
-func (h *CategoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+func (h *CategoryHandler) handlePath(w http.ResponseWriter, r *http.Request) error {
+	id := pathInt(r, "id")
+	path, err := h.Categories.Path(id)
+	if err == datalayer.ErrNotFound {
+		outErr := fmt.Errorf("Category with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(path)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chytilp/links/model"
+)
+
+func TestCategoryEndpointShouldReturnTree(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("user", false)
+	root := server.createCategory("root", 0)
+	server.createCategory("child", root.ID)
+	response := server.do("GET", "/category/tree", "", cookie)
+	server.expectStatus(response, 200, "GET /category/tree")
+	var tree []*model.CategoryNode
+	server.decode(response, &tree)
+	if len(tree) != 1 || len(tree[0].Children) != 1 || tree[0].Children[0].Name != "child" {
+		t.Errorf("GET /category/tree should return root with child, but returns: %s", response.Body.String())
+	}
+}
+
+func TestCategoryEndpointShouldRejectCycle(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("admin", true)
+	root := server.createCategory("root", 0)
+	child := server.createCategory("child", root.ID)
+	body := fmt.Sprintf(`{"Name": "root", "ParentID": %d}`, child.ID)
+	response := server.do("PUT", fmt.Sprintf("/category/%d", root.ID), body, cookie)
+	server.expectStatus(response, 400, "PUT /category/{id}")
+}
+
+func TestCategoryEndpointShouldDeleteWithLinksOnlyInCascade(t *testing.T) {
+	server := newTestServer(t)
+	user, _ := server.createUser("user", false)
+	_, cookie := server.createUser("admin", true)
+	category := server.createCategory("golang", 0)
+	link := server.createLink(user, "golang", category)
+	target := fmt.Sprintf("/category/%d", category.ID)
+	response := server.do("DELETE", target, "", cookie)
+	server.expectStatus(response, 409, "DELETE /category/{id}")
+
+	response = server.do("DELETE", target+"?cascade=true", "", cookie)
+	server.expectStatus(response, 200, "DELETE /category/{id}?cascade=true")
+	archived, err := server.store.Links.IncludeInactive().Get(link.ID)
+	if err != nil || archived.Active == nil {
+		t.Errorf("DELETE /category/{id}?cascade=true should archive link, but returns: %v, %v", archived, err)
+	}
+}
+
+func TestCategoryEndpointShouldDeleteWithLinksOfSubcategoriesOnlyInCascade(t *testing.T) {
+	server := newTestServer(t)
+	user, _ := server.createUser("user", false)
+	_, cookie := server.createUser("admin", true)
+	parent := server.createCategory("languages", 0)
+	child := server.createCategory("golang", parent.ID)
+	link := server.createLink(user, "golang", child)
+	target := fmt.Sprintf("/category/%d", parent.ID)
+	response := server.do("DELETE", target, "", cookie)
+	server.expectStatus(response, 409, "DELETE /category/{id} with links in subcategory")
+
+	response = server.do("DELETE", target+"?cascade=true", "", cookie)
+	server.expectStatus(response, 200, "DELETE /category/{id}?cascade=true with subcategory")
+	archived, err := server.store.Links.IncludeInactive().Get(link.ID)
+	if err != nil || archived.Active == nil {
+		t.Errorf("DELETE /category/{id}?cascade=true should archive link of subcategory, but returns: %v, %v",
+			archived, err)
+	}
+	archivedChild, err := server.store.Categories.Get(child.ID)
+	if err != nil || archivedChild.Active == nil {
+		t.Errorf("DELETE /category/{id}?cascade=true should archive subcategory, but returns: %v, %v",
+			archivedChild, err)
+	}
+}
+
+func TestCategoryEndpointShouldAllowChangesOnlyToSuperadmin(t *testing.T) {
+	server := newTestServer(t)
+	owner, _ := server.createUser("owner", false)
+	_, cookie := server.createUser("user", false)
+	category := server.createCategory("golang", 0)
+	link := server.createLink(owner, "golang", category)
+	target := fmt.Sprintf("/category/%d", category.ID)
+
+	response := server.do("DELETE", target+"?cascade=true", "", cookie)
+	server.expectStatus(response, 403, "DELETE /category/{id}?cascade=true by other user")
+	kept, err := server.store.Links.Get(link.ID)
+	if err != nil || kept.Active != nil {
+		t.Errorf("DELETE /category/{id}?cascade=true by other user should keep link, but returns: %v, %v",
+			kept, err)
+	}
+	response = server.do("PUT", target, `{"Name": "go", "ParentID": 0}`, cookie)
+	server.expectStatus(response, 403, "PUT /category/{id} by other user")
+	response = server.do("POST", "/category/", `{"Name": "rust", "ParentID": 0}`, cookie)
+	server.expectStatus(response, 403, "POST /category/ by other user")
+	response = server.do("DELETE", target, "", nil)
+	server.expectStatus(response, 401, "DELETE /category/{id} without session")
+}
+
+func TestCategoryEndpointShouldValidateCategory(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("admin", true)
+	response := server.do("POST", "/category/", `{"Name": "", "ParentID": 42}`, cookie)
+	server.expectStatus(response, 400, "POST /category/ with invalid category")
+	var body map[string]APIError
+	server.decode(response, &body)
+	details := body["error"].Details
+	if details["Name"] != "is required" || details["ParentID"] != "category with id=42 does not exist" {
+		t.Errorf("POST /category/ should return errors of Name and ParentID, but returns: %s",
+			response.Body.String())
+	}
+}
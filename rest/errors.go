@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/logging"
+)
+
+// requestIDHeader is header with id of request, it is taken from request
+// when client sends valid one and always returned in response.
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID matches request ids accepted from clients.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// APIError is error returned by all endpoints as {"error": {...}}. Code is
+// machine readable kind of error, Details describe problems of single
+// fields, e.g. {"Name": "is required"}.
+type APIError struct {
+	Status    int               `json:"-"`
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// errorCodes are codes of APIError by http status.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusInternalServerError:   "internal_error",
+}
+
+// newAPIError creates APIError with status and message of err.
+func newAPIError(status int, err error) *APIError {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = "error"
+	}
+	return &APIError{Status: status, Code: code, Message: err.Error()}
+}
+
+// apiErrorFrom maps errors of datalayer to APIError with matching status,
+// unknown errors become 500 without their message, so no internals leak.
+func apiErrorFrom(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var duplicateErr *datalayer.DuplicateLinkError
+	switch {
+	case errors.As(err, &duplicateErr):
+		apiErr = newAPIError(http.StatusConflict, err)
+		apiErr.Details = map[string]string{"link": fmt.Sprintf("/link/%d", duplicateErr.ExistingID)}
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, datalayer.ErrNotFound):
+		apiErr = newAPIError(http.StatusNotFound, err)
+	case errors.Is(err, datalayer.ErrForbidden):
+		apiErr = newAPIError(http.StatusForbidden, err)
+	case errors.Is(err, datalayer.ErrInvalidFilter), errors.Is(err, datalayer.ErrInvalidTag),
+		errors.Is(err, datalayer.ErrCategoryCycle), errors.Is(err, datalayer.ErrParentNotFound),
+		errors.Is(err, datalayer.ErrInvalidStars), errors.Is(err, datalayer.ErrPasswordRequired),
+		errors.Is(err, datalayer.ErrDuplicateRole), errors.Is(err, datalayer.ErrRoleRequired):
+		apiErr = newAPIError(http.StatusBadRequest, err)
+	case errors.Is(err, datalayer.ErrDuplicateTag), errors.Is(err, datalayer.ErrDuplicateLink),
+		errors.Is(err, datalayer.ErrDuplicateEmail), errors.Is(err, datalayer.ErrDuplicateUserRole):
+		apiErr = newAPIError(http.StatusConflict, err)
+	default:
+		apiErr = newAPIError(http.StatusInternalServerError, fmt.Errorf("Internal server error"))
+	}
+	return apiErr
+}
+
+// writeError writes err as error response with status chosen by apiErrorFrom.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := apiErrorFrom(err)
+	if apiErr.Status == http.StatusInternalServerError {
+		logging.L.Error("Request %s failed. err: %s\n", w.Header().Get(requestIDHeader), err)
+	}
+	writeAPIError(w, apiErr)
+}
+
+// writeAPIError writes apiErr with id of request as {"error": apiErr}.
+func writeAPIError(w http.ResponseWriter, apiErr *APIError) {
+	response := *apiErr
+	response.RequestID = w.Header().Get(requestIDHeader)
+	output, _ := json.Marshal(map[string]*APIError{"error": &response})
+	prepareResponseFromBytes(w, output, apiErr.Status)
+}
+
+// RequestID wraps handler so every request has id, which is returned in
+// X-Request-ID header and in error responses. Valid id sent by client is kept.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newRequestID returns random request id.
+func newRequestID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// notFound writes 404 error response for paths without endpoint.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	prepareResponseFromError(w, fmt.Errorf("Path %s was not found", r.URL.Path), 404)
+}
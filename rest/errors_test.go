@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+func TestErrorResponseShouldHaveEnvelopeWithRequestID(t *testing.T) {
+	server := newTestServer(t)
+	response := server.do("GET", "/unknown", "", nil)
+	server.expectStatus(response, 404, "GET /unknown")
+	var body map[string]APIError
+	server.decode(response, &body)
+	requestID := response.Header().Get(requestIDHeader)
+	apiErr := body["error"]
+	if apiErr.Code != "not_found" || apiErr.Message == "" || requestID == "" || apiErr.RequestID != requestID {
+		t.Errorf("GET /unknown should return not_found error with request id %s, but returns: %s", requestID,
+			response.Body.String())
+	}
+
+	request := httptest.NewRequest("GET", "/link/", nil)
+	request.Header.Set(requestIDHeader, "client-id-1")
+	recorder := httptest.NewRecorder()
+	server.handler.ServeHTTP(recorder, request)
+	server.expectStatus(recorder, 401, "GET /link/ without session")
+	server.decode(recorder, &body)
+	if body["error"].Code != "unauthorized" || body["error"].RequestID != "client-id-1" {
+		t.Errorf("GET /link/ should return unauthorized error with client request id, but returns: %s",
+			recorder.Body.String())
+	}
+}
+
+func TestAPIErrorFromShouldMapDatalayerErrors(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{sql.ErrNoRows, 404, "not_found"},
+		{fmt.Errorf("category: %w", datalayer.ErrNotFound), 404, "not_found"},
+		{datalayer.ErrForbidden, 403, "forbidden"},
+		{fmt.Errorf("%w: bad sort", datalayer.ErrInvalidFilter), 400, "bad_request"},
+		{datalayer.ErrDuplicateTag, 409, "conflict"},
+		{datalayer.ErrDuplicateUserRole, 409, "conflict"},
+		{&datalayer.DuplicateLinkError{ExistingID: 3}, 409, "conflict"},
+		{errors.New("connection refused"), 500, "internal_error"},
+	}
+	for _, c := range cases {
+		apiErr := apiErrorFrom(c.err)
+		if apiErr.Status != c.status || apiErr.Code != c.code {
+			t.Errorf("apiErrorFrom[%v] should return %d %s, but returns: %d %s", c.err, c.status, c.code,
+				apiErr.Status, apiErr.Code)
+		}
+	}
+	if apiErr := apiErrorFrom(errors.New("connection refused")); apiErr.Message == "connection refused" {
+		t.Errorf("apiErrorFrom should hide message of unknown error, but returns: %s", apiErr.Message)
+	}
+	if apiErr := apiErrorFrom(&datalayer.DuplicateLinkError{ExistingID: 3}); apiErr.Details["link"] != "/link/3" {
+		t.Errorf("apiErrorFrom should point at duplicate link, but returns details: %v", apiErr.Details)
+	}
+}
+
+// failingCategories is CategoryStore which fails to retrieve categories.
+type failingCategories struct {
+	datalayer.CategoryStore
+}
+
+func (c *failingCategories) Retrieve(filters map[string][]string) ([]*model.Category, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRetrieveShouldMapErrorsLikeOtherEndpoints(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("alice", false)
+	response := server.do("GET", "/category/?unknown=1", "", cookie)
+	server.expectStatus(response, 400, "GET /category/ with unknown filter")
+
+	router := NewRouter()
+	(&CategoryHandler{Categories: &failingCategories{server.store.Categories}}).Routes(router)
+	server.handler = router
+	response = server.do("GET", "/category/", "", nil)
+	server.expectStatus(response, 500, "GET /category/ with failing store")
+	if strings.Contains(response.Body.String(), "connection refused") {
+		t.Errorf("GET /category/ should hide message of store error, but returns: %s", response.Body.String())
+	}
+}
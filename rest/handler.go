@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/chytilp/links/datalayer"
+)
+
+// NewHandler returns handler serving all endpoints with repositories from store.
+// Every request gets id, see RequestID.
+func NewHandler(store *datalayer.Store) http.Handler {
+	router := NewRouter()
+	(&AuthHandler{Users: store.Users, Sessions: store.Sessions}).Routes(router)
+	authenticated := router.With(func(next http.Handler) http.Handler {
+		return Authenticate(store.Sessions, next)
+	})
+	(&LinkHandler{Links: store.Links, Categories: store.Categories, Stars: store.Stars,
+		Notes: store.Notes}).Routes(authenticated)
+	(&SearchHandler{Links: store.Links}).Routes(authenticated)
+	(&CategoryHandler{Categories: store.Categories}).Routes(authenticated)
+	(&TagHandler{Tags: store.Tags}).Routes(authenticated)
+	(&UserHandler{Users: store.Users, Roles: store.Roles}).Routes(authenticated)
+	(&RoleHandler{Roles: store.Roles, Links: store.Links}).Routes(authenticated)
+	(&UserRoleHandler{Users: store.Users, Roles: store.Roles, UserRoles: store.UserRoles}).Routes(authenticated)
+	return RequestID(router)
+}
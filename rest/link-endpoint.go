@@ -1,10 +1,12 @@
 package rest
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"path"
+	"reflect"
 	"strconv"
 	"time"
 
@@ -13,58 +15,114 @@ import (
 )
 
 // LinkHandler type is type for handling requests to link endpoint.
-type LinkHandler struct{}
+type LinkHandler struct {
+	Links      datalayer.LinkStore
+	Categories datalayer.CategoryStore
+	Stars      datalayer.StarStore
+	Notes      datalayer.NoteStore
+}
 
-func (h *LinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var err error
-	switch r.Method {
-	case "GET":
-		err = h.handleGet(w, r)
-	case "POST":
-		err = h.handlePost(w, r)
-	case "PUT":
-		err = h.handlePut(w, r)
-	case "DELETE":
-		err = h.handleDelete(w, r)
-	}
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// Routes registers routes of link endpoint and its subresources to router.
+func (h *LinkHandler) Routes(router *Router) {
+	router.HandleUser("GET", "/link/", h.handleRetrieve)
+	router.HandleUser("POST", "/link/", h.handlePost)
+	router.HandleUser("GET", "/link/{id:int}", h.handleGet)
+	router.HandleUser("PUT", "/link/{id:int}", h.handlePut)
+	router.HandleUser("PATCH", "/link/{id:int}", h.handlePatch)
+	router.HandleUser("DELETE", "/link/{id:int}", h.handleDelete)
+	router.HandleUser("POST", "/link/{id:int}/restore", h.handleRestore)
+	router.HandleUser("GET", "/link/{id:int}/stars", h.handleGetStars)
+	router.HandleUser("POST", "/link/{id:int}/stars", h.handleSetStars)
+	router.HandleUser("PUT", "/link/{id:int}/stars", h.handleSetStars)
+	router.HandleUser("DELETE", "/link/{id:int}/stars", h.handleDeleteStars)
+	router.HandleUser("GET", "/link/{id:int}/notes", h.handleGetNotes)
+	router.HandleUser("POST", "/link/{id:int}/notes", h.handlePostNote)
+	router.HandleUser("GET", "/link/{id:int}/notes/{noteId:int}", h.handleGetNote)
+	router.HandleUser("PUT", "/link/{id:int}/notes/{noteId:int}", h.handlePutNote)
+	router.HandleUser("DELETE", "/link/{id:int}/notes/{noteId:int}", h.handleDeleteNote)
+}
+
+// visibleLink returns id of link from path, when links have no such link
+// it writes 404 response and returns false.
+func visibleLink(w http.ResponseWriter, r *http.Request, links datalayer.LinkStore) (int, bool, error) {
+	id := pathInt(r, "id")
+	if _, err := links.Get(id); err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Link with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
 	}
+	return id, true, nil
 }
 
-func (h *LinkHandler) handleGet(w http.ResponseWriter, r *http.Request) error {
-	var err error
-	urlPath := path.Base(r.URL.Path)
-	if urlPath == "link" {
-		return h.handleRetrieve(w, r)
+func (h *LinkHandler) handleGet(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	id := pathInt(r, "id")
+	links, ok := h.scopeInactive(w, r, user)
+	if !ok {
+		return nil
 	}
-	id, err := strconv.Atoi(urlPath)
-	if err != nil {
-		outErr := fmt.Errorf("Path parameter wrong type, value: %s . Error: %s", urlPath, err)
+	link, err := links.Get(id)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Link with id=%d was not found. Error: %s", id, err)
 		prepareResponseFromError(w, outErr, 404)
+		return nil
 	}
-	links := datalayer.CreateLinks(nil)
-	defer links.Close()
-	link, err := links.Get(int(id))
 	if err != nil {
-		outErr := fmt.Errorf("Link with id=%d was not found. Error: %s", id, err)
-		prepareResponseFromError(w, outErr, 404)
-	} else {
-		output, _ := json.Marshal(link)
-		prepareResponseFromBytes(w, output, 200)
+		return err
 	}
+	if r.URL.Query().Get("include") == "notes" {
+		if err = h.includeNotes(link, user); err != nil {
+			return err
+		}
+	}
+	output, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
 	return nil
 }
 
-func (h *LinkHandler) handleRetrieve(w http.ResponseWriter, r *http.Request) error {
+func (h *LinkHandler) handleRetrieve(w http.ResponseWriter, r *http.Request, user *model.User) error {
 	queryParams := r.URL.Query()
-	links := datalayer.CreateLinks(nil)
-	defer links.Close()
+	recursive := queryParams.Get("recursive") == "true"
+	queryParams.Del("recursive")
+	if recursive && len(queryParams["c_id"]) > 0 {
+		categoryIDs, err := h.descendantCategoryIDs(queryParams["c_id"])
+		if err != nil {
+			writeError(w, err)
+			return nil
+		}
+		queryParams["c_id"] = categoryIDs
+	}
+	queryParams.Del("include_inactive")
+	page, err := parsePage(queryParams)
+	if err != nil {
+		prepareResponseFromError(w, err, 400)
+		return nil
+	}
+	links, ok := h.scopeInactive(w, r, user)
+	if !ok {
+		return nil
+	}
 	foundLinks, err := links.Retrieve(queryParams)
+	if errors.Is(err, datalayer.ErrInvalidFilter) {
+		prepareResponseFromError(w, err, 400)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	total, err := links.Count(queryParams)
 	if err != nil {
 		return err
 	}
+	lastID := 0
+	if len(foundLinks) > 0 {
+		lastID = foundLinks[len(foundLinks)-1].ID
+	}
+	setPageHeaders(w, r, page, len(foundLinks), lastID, total)
 	content := make([]string, len(foundLinks))
 	for index, link := range foundLinks {
 		bytes, _ := json.Marshal(link)
@@ -80,24 +138,85 @@ func (h *LinkHandler) handleRetrieve(w http.ResponseWriter, r *http.Request) err
 	return nil
 }
 
-func (h *LinkHandler) handlePost(w http.ResponseWriter, r *http.Request) error {
-	err := h.processSave(w, r)
-	if err != nil {
-		return err
+// scopeInactive returns links visible for user, with include_inactive=true
+// also archived links and links in archived categories. Only superadmin can
+// include inactive links, for others it writes 403 response.
+func (h *LinkHandler) scopeInactive(w http.ResponseWriter, r *http.Request, user *model.User) (datalayer.LinkStore,
+	bool) {
+	links := h.Links.ForUser(user)
+	if r.URL.Query().Get("include_inactive") != "true" {
+		return links, true
 	}
-	return nil
+	if !user.Superadmin {
+		prepareResponseFromError(w, fmt.Errorf("Only superadmin can include inactive links"), 403)
+		return nil, false
+	}
+	return links.IncludeInactive(), true
+}
+
+// descendantCategoryIDs expands category ids to ids of categories and all their subcategories.
+func (h *LinkHandler) descendantCategoryIDs(values []string) ([]string, error) {
+	seen := make(map[int]bool)
+	var result []string
+	for _, value := range values {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: Query parameter c_id wrong type, value: %s . Error: %s",
+				datalayer.ErrInvalidFilter, value, err)
+		}
+		ids, err := h.Categories.DescendantIDs(id)
+		if err == datalayer.ErrNotFound {
+			return nil, fmt.Errorf("%w: Category with id=%d was not found", datalayer.ErrInvalidFilter, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, descendantID := range ids {
+			if !seen[descendantID] {
+				seen[descendantID] = true
+				result = append(result, strconv.Itoa(descendantID))
+			}
+		}
+	}
+	return result, nil
+}
+
+func (h *LinkHandler) handlePost(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	return h.processSave(w, r, user, 0)
 }
 
-func (h *LinkHandler) processSave(w http.ResponseWriter, r *http.Request) error {
-	len := r.ContentLength
-	body := make([]byte, len)
-	r.Body.Read(body)
+// processSave validates link from request body and saves it, id is id of
+// changed link from path, 0 for new link.
+func (h *LinkHandler) processSave(w http.ResponseWriter, r *http.Request, user *model.User, id int) error {
 	var link model.Link
-	json.Unmarshal(body, &link)
-	links := datalayer.CreateLinks(nil)
-	defer links.Close()
+	if err := decodeBody(r, &link, "link"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if err := validateLink(&link, nil, h.Categories); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	link.ID = id
+	links := h.Links.ForUser(user)
+	if r.URL.Query().Get("allow_duplicate") == "true" {
+		links = links.AllowDuplicate()
+	}
 	var outLink *model.Link
 	outLink, err := links.Save(link)
+	var duplicateErr *datalayer.DuplicateLinkError
+	if errors.As(err, &duplicateErr) {
+		w.Header().Set("Location", fmt.Sprintf("/link/%d", duplicateErr.ExistingID))
+	}
+	if errors.Is(err, datalayer.ErrInvalidTag) || errors.Is(err, datalayer.ErrDuplicateLink) {
+		writeError(w, err)
+		return nil
+	}
+	if err == datalayer.ErrForbidden {
+		outErr := fmt.Errorf("Link with id=%d can be changed only by its owner", link.ID)
+		prepareResponseFromError(w, outErr, 403)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -107,39 +226,148 @@ func (h *LinkHandler) processSave(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return err
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(201)
-	w.Write(output)
+	prepareResponseFromBytes(w, output, 201)
 	return nil
 }
 
-func (h *LinkHandler) handlePut(w http.ResponseWriter, r *http.Request) error {
-	err := h.processSave(w, r)
+func (h *LinkHandler) handlePut(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	id := pathInt(r, "id")
+	// not visible link is not found, visible link of another owner is forbidden by Save
+	if _, err := h.Links.ForUser(user).IncludeInactive().Get(id); err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Link with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return h.processSave(w, r, user, id)
+}
+
+// handlePatch changes only fields of link sent in JSON Merge Patch on
+// PATCH /link/{id}, e.g. {"Name": "Go"} renames link and keeps its url and
+// category. Tags null removes all tags.
+func (h *LinkHandler) handlePatch(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	id := pathInt(r, "id")
+	links := h.Links.ForUser(user)
+	// not visible link is not found, visible link of another owner is forbidden
+	original, err := links.IncludeInactive().Get(id)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Link with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	allowed, err := links.CanWrite(id)
 	if err != nil {
 		return err
 	}
+	if !allowed {
+		outErr := fmt.Errorf("Link with id=%d can be changed only by its owner", id)
+		prepareResponseFromError(w, outErr, 403)
+		return nil
+	}
+	var link model.Link
+	if err := applyMergePatch(r, original, &link, "link"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if err := validateLink(&link, original, h.Categories); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if r.URL.Query().Get("allow_duplicate") == "true" {
+		links = links.AllowDuplicate()
+	}
+	outLink, err := links.Patch(id, linkChanges(original, &link))
+	var duplicateErr *datalayer.DuplicateLinkError
+	if errors.As(err, &duplicateErr) {
+		w.Header().Set("Location", fmt.Sprintf("/link/%d", duplicateErr.ExistingID))
+	}
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(outLink)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
 	return nil
 }
 
-func (h *LinkHandler) handleDelete(w http.ResponseWriter, r *http.Request) error {
-	id, err := strconv.Atoi(path.Base(r.URL.Path))
+// linkChanges returns patch with fields of patched link which differ from original.
+func linkChanges(original *model.Link, patched *model.Link) datalayer.LinkPatch {
+	var patch datalayer.LinkPatch
+	if patched.Link != original.Link {
+		patch.Link = &patched.Link
+	}
+	if patched.Name != original.Name {
+		patch.Name = &patched.Name
+	}
+	if patched.Category.ID != original.Category.ID {
+		patch.CategoryID = &patched.Category.ID
+	}
+	if !reflect.DeepEqual(patched.Tags, original.Tags) {
+		patch.Tags = patched.Tags
+		if patch.Tags == nil {
+			patch.Tags = []string{}
+		}
+	}
+	return patch
+}
+
+func (h *LinkHandler) handleDelete(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	id := pathInt(r, "id")
+	links := h.Links.ForUser(user)
+	link, err := links.Get(id)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Link with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	links := datalayer.CreateLinks(nil)
-	defer links.Close()
-	link, err := links.Get(int(id))
+	now := time.Now()
+	_, err = links.Delete(id, now)
+	if err == datalayer.ErrForbidden {
+		outErr := fmt.Errorf("Link with id=%d can be changed only by its owner", id)
+		prepareResponseFromError(w, outErr, 403)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	now := time.Now()
-	_, err = links.Delete(int(id), now)
+	output, err := json.Marshal(link)
 	if err != nil {
 		return err
 	}
-	output, _ := json.Marshal(link)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(output)
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+// handleRestore activates archived link on POST /link/{id}/restore.
+func (h *LinkHandler) handleRestore(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	// archived link can be found only by restore
+	links := h.Links.ForUser(user).IncludeInactive()
+	id, ok, err := visibleLink(w, r, links)
+	if !ok {
+		return err
+	}
+	link, err := links.Restore(id)
+	if err == datalayer.ErrForbidden {
+		outErr := fmt.Errorf("Link with id=%d can be changed only by its owner", id)
+		prepareResponseFromError(w, outErr, 403)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
 	return nil
 }
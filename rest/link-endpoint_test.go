@@ -0,0 +1,398 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+func TestLinkEndpointShouldRequireSession(t *testing.T) {
+	server := newTestServer(t)
+	response := server.do("GET", "/link/", "", nil)
+	server.expectStatus(response, 401, "GET /link/")
+}
+
+func TestLinkEndpointShouldCreateAndReturnLink(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("owner", false)
+	category := server.createCategory("golang", 0)
+	body := fmt.Sprintf(`{"Link": "https://golang.org", "Name": "Go", "Category": {"ID": %d}}`, category.ID)
+	response := server.do("POST", "/link/", body, cookie)
+	server.expectStatus(response, 201, "POST /link/")
+	var created map[string]int
+	server.decode(response, &created)
+
+	response = server.do("GET", fmt.Sprintf("/link/%d", created["id"]), "", cookie)
+	server.expectStatus(response, 200, "GET /link/{id}")
+	var link model.Link
+	server.decode(response, &link)
+	if link.Name != "Go" || link.Category == nil || link.Category.ID != category.ID {
+		t.Errorf("GET /link/{id} should return created link, but returns: %#v", link)
+	}
+}
+
+func TestLinkEndpointShouldHideLinkOfOtherUser(t *testing.T) {
+	server := newTestServer(t)
+	owner, _ := server.createUser("owner", false)
+	_, otherCookie := server.createUser("other", false)
+	link := server.createLink(owner, "golang", server.createCategory("golang", 0))
+	response := server.do("GET", fmt.Sprintf("/link/%d", link.ID), "", otherCookie)
+	server.expectStatus(response, 404, "GET /link/{id}")
+
+	response = server.do("GET", "/link/", "", otherCookie)
+	server.expectStatus(response, 200, "GET /link/")
+	var links []string
+	server.decode(response, &links)
+	if len(links) != 0 {
+		t.Errorf("GET /link/ should return no links to other user, but returns: %v", links)
+	}
+}
+
+func TestLinkEndpointShouldAllowChangesOnlyToOwner(t *testing.T) {
+	server := newTestServer(t)
+	owner, _ := server.createUser("owner", false)
+	_, adminCookie := server.createUser("admin", true)
+	category := server.createCategory("golang", 0)
+	link := server.createLink(owner, "golang", category)
+	body := fmt.Sprintf(`{"ID": %d, "Link": "https://go.dev", "Name": "Go", "Category": {"ID": %d}}`,
+		link.ID, category.ID)
+	_, otherCookie := server.createUser("other", false)
+	response := server.do("PUT", fmt.Sprintf("/link/%d", link.ID), body, otherCookie)
+	server.expectStatus(response, 404, "PUT /link/{id} of not visible link")
+	response = server.do("PUT", "/link/999", body, otherCookie)
+	server.expectStatus(response, 404, "PUT /link/{id} of missing link")
+
+	response = server.do("DELETE", fmt.Sprintf("/link/%d", link.ID), "", adminCookie)
+	server.expectStatus(response, 200, "DELETE /link/{id}")
+	deleted, err := server.store.Links.IncludeInactive().Get(link.ID)
+	if err != nil || deleted.Active == nil {
+		t.Errorf("DELETE /link/{id} should archive link, but returns: %v, %v", deleted, err)
+	}
+}
+
+func TestLinkEndpointShouldForbidChangesOfSharedLink(t *testing.T) {
+	server, db := newSQLiteTestServer(t)
+	owner, _ := server.createUser("owner", false)
+	reader, cookie := server.createUser("reader", false)
+	category := server.createCategory("golang", 0)
+	byUser := server.createLink(owner, "golang", category)
+	byRole := server.createLink(owner, "gophers", category)
+	role, err := server.store.Roles.Save(model.Role{Name: "gophers"})
+	if err != nil {
+		t.Fatalf("Roles.Save should save role, but error: %v", err)
+	}
+	if _, err := server.store.UserRoles.Save(model.UserRole{User: reader, Role: role}); err != nil {
+		t.Fatalf("UserRoles.Save should assign role, but error: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO user_link(user_id, link_id, owner) VALUES(?, ?, ?)",
+		reader.ID, byUser.ID, false); err != nil {
+		t.Fatalf("user_link should be inserted, but error: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO role_link(role_id, link_id) VALUES(?, ?)", role.ID, byRole.ID); err != nil {
+		t.Fatalf("role_link should be inserted, but error: %v", err)
+	}
+
+	for _, link := range []*model.Link{byUser, byRole} {
+		target := fmt.Sprintf("/link/%d", link.ID)
+		response := server.do("GET", target, "", cookie)
+		server.expectStatus(response, 200, "GET /link/{id} of shared link")
+		body := fmt.Sprintf(`{"ID": %d, "Link": "https://go.dev", "Name": "Go", "Category": {"ID": %d}}`,
+			link.ID, category.ID)
+		response = server.do("PUT", target, body, cookie)
+		server.expectStatus(response, 403, "PUT /link/{id} of shared link")
+		request := httptest.NewRequest("PATCH", target, strings.NewReader(`{"Name": "Go"}`))
+		request.Header.Set("Content-Type", mergePatchType)
+		request.AddCookie(cookie)
+		response = httptest.NewRecorder()
+		server.handler.ServeHTTP(response, request)
+		server.expectStatus(response, 403, "PATCH /link/{id} of shared link")
+		response = server.do("DELETE", target, "", cookie)
+		server.expectStatus(response, 403, "DELETE /link/{id} of shared link")
+		kept, err := server.store.Links.Get(link.ID)
+		if err != nil || kept.Name != link.Name || kept.Active != nil {
+			t.Errorf("Shared link should not be changed, but returns: %v, %v", kept, err)
+		}
+	}
+}
+
+func TestLinkEndpointShouldRateLink(t *testing.T) {
+	server := newTestServer(t)
+	owner, ownerCookie := server.createUser("owner", false)
+	link := server.createLink(owner, "golang", server.createCategory("golang", 0))
+	target := fmt.Sprintf("/link/%d/stars", link.ID)
+	response := server.do("POST", target, `{"Stars": 6}`, ownerCookie)
+	server.expectStatus(response, 400, "POST /link/{id}/stars")
+	response = server.do("POST", target, `{"Stars": 4}`, ownerCookie)
+	server.expectStatus(response, 200, "POST /link/{id}/stars")
+
+	response = server.do("GET", fmt.Sprintf("/link/%d", link.ID), "", ownerCookie)
+	var rated model.Link
+	server.decode(response, &rated)
+	if rated.Rating != 4 || rated.RatingCount != 1 {
+		t.Errorf("Link should have rating 4 from 1 user, but has %v from %d", rated.Rating,
+			rated.RatingCount)
+	}
+	response = server.do("DELETE", target, "", ownerCookie)
+	server.expectStatus(response, 204, "DELETE /link/{id}/stars")
+}
+
+func TestLinkEndpointShouldValidateNotesAndStars(t *testing.T) {
+	server := newTestServer(t)
+	owner, cookie := server.createUser("owner", false)
+	link := server.createLink(owner, "golang", server.createCategory("golang", 0))
+	cases := []struct {
+		target string
+		body   string
+		field  string
+	}{
+		{fmt.Sprintf("/link/%d/notes", link.ID), `{"Note": "  "}`, "Note"},
+		{fmt.Sprintf("/link/%d/stars", link.ID), `{"Stars": 0}`, "Stars"},
+		{fmt.Sprintf("/link/%d/stars", link.ID), `{"Stars": 6}`, "Stars"},
+	}
+	for _, c := range cases {
+		response := server.do("POST", c.target, c.body, cookie)
+		server.expectStatus(response, 400, "POST "+c.target+" "+c.body)
+		var body map[string]APIError
+		server.decode(response, &body)
+		if body["error"].Code != "validation_failed" || body["error"].Details[c.field] == "" {
+			t.Errorf("POST %s %s should return %s detail, but returns: %v", c.target, c.body, c.field, body)
+		}
+	}
+}
+
+func TestLinkEndpointShouldKeepPrivateNotesPrivate(t *testing.T) {
+	server := newTestServer(t)
+	owner, ownerCookie := server.createUser("owner", false)
+	_, adminCookie := server.createUser("admin", true)
+	link := server.createLink(owner, "golang", server.createCategory("golang", 0))
+	target := fmt.Sprintf("/link/%d/notes", link.ID)
+	response := server.do("POST", target, `{"Note": "secret", "Private": true}`, ownerCookie)
+	server.expectStatus(response, 201, "POST /link/{id}/notes")
+	var created map[string]int
+	server.decode(response, &created)
+
+	response = server.do("GET", target, "", adminCookie)
+	server.expectStatus(response, 200, "GET /link/{id}/notes")
+	var notes []*model.Note
+	server.decode(response, &notes)
+	if len(notes) != 0 {
+		t.Errorf("GET /link/{id}/notes should hide private note, but returns: %v", notes)
+	}
+	noteTarget := fmt.Sprintf("%s/%d", target, created["id"])
+	response = server.do("DELETE", noteTarget, "", adminCookie)
+	server.expectStatus(response, 404, "DELETE /link/{id}/notes/{noteId}")
+
+	response = server.do("GET", fmt.Sprintf("/link/%d?include=notes", link.ID), "", ownerCookie)
+	var withNotes model.Link
+	if err := json.Unmarshal(response.Body.Bytes(), &withNotes); err != nil || withNotes.Notes == nil ||
+		len(*withNotes.Notes) != 1 {
+		t.Errorf("GET /link/{id}?include=notes should return own note, but returns: %s", response.Body.String())
+	}
+}
+
+func TestLinkEndpointShouldPageLinks(t *testing.T) {
+	server := newTestServer(t)
+	owner, cookie := server.createUser("owner", false)
+	category := server.createCategory("golang", 0)
+	for _, name := range []string{"a", "b", "c"} {
+		server.createLink(owner, name, category)
+	}
+	response := server.do("GET", "/link/?limit=2&sort=-l_name", "", cookie)
+	server.expectStatus(response, 200, "GET /link/?limit=2")
+	var links []string
+	server.decode(response, &links)
+	if len(links) != 2 {
+		t.Errorf("GET /link/?limit=2 should return 2 links, but returns: %v", links)
+	}
+	if total := response.Header().Get("X-Total-Count"); total != "3" {
+		t.Errorf("GET /link/?limit=2 should return total count 3, but returns: %s", total)
+	}
+	expected := `</link/?limit=2&offset=2&sort=-l_name>; rel="next"`
+	if link := response.Header().Get("Link"); link != expected {
+		t.Errorf("GET /link/?limit=2 should return Link header %s, but returns: %s", expected, link)
+	}
+
+	response = server.do("GET", "/link/?limit=2&offset=2", "", cookie)
+	expected = `</link/?limit=2&offset=0>; rel="prev"`
+	if link := response.Header().Get("Link"); link != expected {
+		t.Errorf("GET /link/?offset=2 should return Link header %s, but returns: %s", expected, link)
+	}
+
+	for _, query := range []string{"limit=x", "limit=5000", "sort=password", "offset=1&after=1"} {
+		response = server.do("GET", "/link/?"+query, "", cookie)
+		server.expectStatus(response, 400, "GET /link/?"+query)
+	}
+}
+
+func TestLinkEndpointShouldRejectUnknownFilterOperator(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("owner", false)
+	response := server.do("GET", "/link/?l_name[between]=a", "", cookie)
+	server.expectStatus(response, 400, "GET /link/?l_name[between]=a")
+	if !strings.Contains(response.Body.String(), "between") {
+		t.Errorf("GET /link/?l_name[between]=a should name operator in error, but returns: %s",
+			response.Body.String())
+	}
+}
+
+func TestLinkEndpointShouldHideAndRestoreInactiveLinks(t *testing.T) {
+	server := newTestServer(t)
+	owner, cookie := server.createUser("owner", false)
+	_, adminCookie := server.createUser("admin", true)
+	category := server.createCategory("golang", 0)
+	link := server.createLink(owner, "golang", category)
+	target := fmt.Sprintf("/link/%d", link.ID)
+	response := server.do("DELETE", target, "", cookie)
+	server.expectStatus(response, 200, "DELETE /link/{id}")
+
+	response = server.do("GET", target, "", cookie)
+	server.expectStatus(response, 404, "GET /link/{id} of archived link")
+	response = server.do("GET", "/link/", "", adminCookie)
+	if total := response.Header().Get("X-Total-Count"); total != "0" {
+		t.Errorf("GET /link/ should hide archived link, but returns total count %s", total)
+	}
+	response = server.do("GET", "/link/?include_inactive=true", "", cookie)
+	server.expectStatus(response, 403, "GET /link/?include_inactive=true by user")
+	response = server.do("GET", "/link/?include_inactive=true", "", adminCookie)
+	server.expectStatus(response, 200, "GET /link/?include_inactive=true by superadmin")
+	if total := response.Header().Get("X-Total-Count"); total != "1" {
+		t.Errorf("GET /link/?include_inactive=true should return archived link, but returns total count %s",
+			total)
+	}
+
+	response = server.do("POST", target+"/restore", "", cookie)
+	server.expectStatus(response, 200, "POST /link/{id}/restore")
+	var restored model.Link
+	server.decode(response, &restored)
+	if restored.ID != link.ID || restored.Active != nil {
+		t.Errorf("POST /link/{id}/restore should activate link, but returns: %#v", restored)
+	}
+	response = server.do("GET", target, "", cookie)
+	server.expectStatus(response, 200, "GET /link/{id} of restored link")
+}
+
+func TestLinkEndpointShouldRejectDuplicateURL(t *testing.T) {
+	server := newTestServer(t)
+	owner, cookie := server.createUser("owner", false)
+	category := server.createCategory("golang", 0)
+	link := server.createLink(owner, "golang", category)
+	body := fmt.Sprintf(`{"Link": "HTTPS://Golang.org:443/?utm_source=feed#intro", "Name": "Go", "Category": {"ID": %d}}`,
+		category.ID)
+	response := server.do("POST", "/link/", body, cookie)
+	server.expectStatus(response, 409, "POST /link/ with duplicate url")
+	expected := fmt.Sprintf("/link/%d", link.ID)
+	if location := response.Header().Get("Location"); location != expected {
+		t.Errorf("POST /link/ with duplicate url should point at %s, but points at: %s", expected, location)
+	}
+
+	response = server.do("POST", "/link/?allow_duplicate=true", body, cookie)
+	server.expectStatus(response, 201, "POST /link/?allow_duplicate=true")
+}
+
+func TestLinkEndpointShouldValidateLink(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("owner", false)
+	response := server.do("POST", "/link/", `{"Link": "ftp://example.org", "Name": " "}`, cookie)
+	server.expectStatus(response, 400, "POST /link/ with invalid link")
+	var body map[string]APIError
+	server.decode(response, &body)
+	expected := map[string]string{"Link": "must have scheme http or https", "Name": "is required",
+		"Category": "is required"}
+	if body["error"].Code != "validation_failed" || fmt.Sprint(body["error"].Details) != fmt.Sprint(expected) {
+		t.Errorf("POST /link/ should return errors of fields %v, but returns: %s", expected, response.Body.String())
+	}
+
+	response = server.do("POST", "/link/", `{"Link": "https://example.org", "Name": "x", "Category": {"ID": 99}}`,
+		cookie)
+	server.expectStatus(response, 400, "POST /link/ with missing category")
+	response = server.do("POST", "/link/", `{"Link": `, cookie)
+	server.expectStatus(response, 400, "POST /link/ with malformed json")
+	response = server.do("POST", "/link/", `{"Name": "`+strings.Repeat("x", maxBodySize)+`"}`, cookie)
+	server.expectStatus(response, 413, "POST /link/ with too large body")
+}
+
+func TestLinkEndpointShouldRejectUnknownMethod(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("owner", false)
+	response := server.do("PATCH", "/link/", "", cookie)
+	server.expectStatus(response, 405, "PATCH /link/")
+	if allow := response.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("PATCH /link/ should return Allow header GET, HEAD, OPTIONS, POST, but returns: %s", allow)
+	}
+	response = server.do("GET", "/link/golang", "", cookie)
+	server.expectStatus(response, 404, "GET /link/golang")
+}
+
+func TestLinkEndpointShouldPatchLink(t *testing.T) {
+	server := newTestServer(t)
+	owner, cookie := server.createUser("owner", false)
+	category := server.createCategory("golang", 0)
+	link := server.createLink(owner, "golang", category)
+	target := fmt.Sprintf("/link/%d", link.ID)
+	patch := func(body string, contentType string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("PATCH", target, strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		request.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		server.handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+	response := patch(`{"Name": "Go", "Tags": ["go"], "Rating": 5}`, mergePatchType)
+	server.expectStatus(response, 200, "PATCH /link/{id}")
+	var patched model.Link
+	server.decode(response, &patched)
+	if patched.Name != "Go" || patched.Link != link.Link || patched.Category.ID != category.ID ||
+		fmt.Sprint(patched.Tags) != "[go]" || patched.Rating != 0 {
+		t.Errorf("PATCH /link/{id} should change name and tags only, but returns: %#v", patched)
+	}
+
+	response = patch(`{"Tags": null}`, mergePatchType)
+	server.expectStatus(response, 200, "PATCH /link/{id} removing tags")
+	var untagged model.Link
+	server.decode(response, &untagged)
+	if untagged.Name != "Go" || len(untagged.Tags) != 0 {
+		t.Errorf("PATCH /link/{id} should remove tags, but returns: %#v", untagged)
+	}
+
+	response = patch(`{"Name": null}`, "application/json")
+	server.expectStatus(response, 400, "PATCH /link/{id} removing name")
+	response = patch(`{"Name": "Go"}`, "text/plain")
+	server.expectStatus(response, 415, "PATCH /link/{id} with text/plain")
+
+	if _, err := server.store.Categories.Delete(category.ID, time.Now()); err != nil {
+		t.Fatalf("Categories.Delete should archive category, but error: %v", err)
+	}
+	response = patch(`{"Name": "Go in archive"}`, mergePatchType)
+	server.expectStatus(response, 200, "PATCH /link/{id} in archived category")
+	other := server.createCategory("other", 0)
+	if _, err := server.store.Categories.Delete(other.ID, time.Now()); err != nil {
+		t.Fatalf("Categories.Delete should archive category, but error: %v", err)
+	}
+	response = patch(fmt.Sprintf(`{"Category": {"ID": %d}}`, other.ID), mergePatchType)
+	server.expectStatus(response, 400, "PATCH /link/{id} to archived category")
+
+	_, cookie = server.createUser("other", false)
+	response = patch(`{"Name": "Mine"}`, mergePatchType)
+	server.expectStatus(response, 404, "PATCH /link/{id} by other user")
+}
+
+func TestLinkEndpointShouldNotRevealDuplicateOfHiddenLink(t *testing.T) {
+	server := newTestServer(t)
+	alice, _ := server.createUser("alice", false)
+	_, cookie := server.createUser("bob", false)
+	category := server.createCategory("golang", 0)
+	private := server.createLink(alice, "golang", category)
+	body := fmt.Sprintf(`{"Link": "%s/", "Name": "Go", "Category": {"ID": %d}}`, private.Link, category.ID)
+	response := server.do("POST", "/link/", body, cookie)
+	server.expectStatus(response, 201, "POST /link/ with url of hidden link")
+	if location := response.Header().Get("Location"); location == fmt.Sprintf("/link/%d", private.ID) {
+		t.Errorf("POST /link/ should not point to hidden link, but returns Location: %s", location)
+	}
+	response = server.do("POST", "/link/", body, cookie)
+	server.expectStatus(response, 409, "POST /link/ with url of own link")
+}
@@ -0,0 +1,191 @@
+package rest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chytilp/links/model"
+)
+
+// handleGetNotes returns notes of link visible for user on GET /link/{id}/notes.
+func (h *LinkHandler) handleGetNotes(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	linkID, ok, err := visibleLink(w, r, h.Links.ForUser(user))
+	if !ok {
+		return err
+	}
+	linkNotes, err := h.Notes.ForLink(linkID, user.ID)
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(linkNotes)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+// handlePostNote creates note of logged in user on POST /link/{id}/notes.
+func (h *LinkHandler) handlePostNote(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	linkID, ok, err := visibleLink(w, r, h.Links.ForUser(user))
+	if !ok {
+		return err
+	}
+	note, ok := decodeNote(w, r)
+	if !ok {
+		return nil
+	}
+	note.User = user
+	note.Link = &model.Link{ID: linkID}
+	outNote, err := h.Notes.Save(*note)
+	if err != nil {
+		return err
+	}
+	idmap := make(map[string]int)
+	idmap["id"] = outNote.ID
+	output, err := json.Marshal(idmap)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 201)
+	return nil
+}
+
+// handleGetNote returns one note on GET /link/{id}/notes/{noteId}.
+func (h *LinkHandler) handleGetNote(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	note, ok, err := h.visibleNote(w, r, user)
+	if !ok {
+		return err
+	}
+	output, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+// handlePutNote changes note on PUT /link/{id}/notes/{noteId}, only author
+// can change the note.
+func (h *LinkHandler) handlePutNote(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	note, ok, err := h.authoredNote(w, r, user)
+	if !ok {
+		return err
+	}
+	changed, ok := decodeNote(w, r)
+	if !ok {
+		return nil
+	}
+	changed.ID = note.ID
+	outNote, err := h.Notes.Save(*changed)
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(outNote)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+// handleDeleteNote deletes note on DELETE /link/{id}/notes/{noteId}, only
+// author can delete the note.
+func (h *LinkHandler) handleDeleteNote(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	note, ok, err := h.authoredNote(w, r, user)
+	if !ok {
+		return err
+	}
+	if err := h.Notes.Delete(note.ID); err != nil {
+		return err
+	}
+	output, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+// visibleNote returns note from path of link visible for user, when there
+// is no such note it writes 404 response and returns false.
+func (h *LinkHandler) visibleNote(w http.ResponseWriter, r *http.Request, user *model.User) (*model.Note,
+	bool, error) {
+	linkID, ok, err := visibleLink(w, r, h.Links.ForUser(user))
+	if !ok {
+		return nil, false, err
+	}
+	noteID := pathInt(r, "noteId")
+	note, err := h.Notes.Get(noteID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, err
+	}
+	if err == sql.ErrNoRows || note.Link.ID != linkID || !noteVisibleFor(note, user) {
+		outErr := fmt.Errorf("Note with id=%d was not found", noteID)
+		prepareResponseFromError(w, outErr, 404)
+		return nil, false, nil
+	}
+	return note, true, nil
+}
+
+// authoredNote returns note like visibleNote, when user is not author of
+// the note it writes 403 response and returns false.
+func (h *LinkHandler) authoredNote(w http.ResponseWriter, r *http.Request, user *model.User) (*model.Note,
+	bool, error) {
+	note, ok, err := h.visibleNote(w, r, user)
+	if !ok {
+		return nil, false, err
+	}
+	if note.User.ID != user.ID {
+		outErr := fmt.Errorf("Note with id=%d can be changed only by its author", note.ID)
+		prepareResponseFromError(w, outErr, 403)
+		return nil, false, nil
+	}
+	return note, true, nil
+}
+
+// includeNotes embeds notes visible for user to link.
+func (h *LinkHandler) includeNotes(link *model.Link, user *model.User) error {
+	linkNotes, err := h.Notes.ForLink(link.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, note := range linkNotes {
+		// note is embedded in its link already
+		note.Link = nil
+	}
+	link.Notes = &linkNotes
+	return nil
+}
+
+// validateNote trims text of note and checks it.
+func validateNote(note *model.Note) error {
+	var v validator
+	note.Note = strings.TrimSpace(note.Note)
+	v.required("Note", note.Note)
+	return v.err()
+}
+
+// decodeNote reads note from request body, when body is not valid note
+// it writes 400 response.
+func decodeNote(w http.ResponseWriter, r *http.Request) (*model.Note, bool) {
+	var note model.Note
+	if err := decodeBody(r, &note, "note"); err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	if err := validateNote(&note); err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return &note, true
+}
+
+// noteVisibleFor checks if note can be read by user, private notes are
+// visible only to their author.
+func noteVisibleFor(note *model.Note, user *model.User) bool {
+	return !note.Private || note.User.ID == user.ID
+}
@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/chytilp/links/datalayer"
+)
+
+const (
+	// defaultPageLimit is number of records returned when request has no limit
+	defaultPageLimit = 100
+	// maxPageLimit is the highest limit client can ask for
+	maxPageLimit = 1000
+)
+
+// parsePage reads page from query parameters and fills in default limit.
+func parsePage(queryParams url.Values) (datalayer.Page, error) {
+	page, err := datalayer.ParsePage(queryParams)
+	if err != nil {
+		return page, err
+	}
+	if page.Limit == 0 {
+		page.Limit = defaultPageLimit
+	}
+	if page.Limit > maxPageLimit {
+		return page, fmt.Errorf("Parameter limit can be at most %d, value: %d", maxPageLimit, page.Limit)
+	}
+	queryParams.Set("limit", strconv.Itoa(page.Limit))
+	return page, nil
+}
+
+// setPageHeaders sets X-Total-Count header with number of all matching
+// records and Link header with urls of next and previous page. Request with
+// after parameter is paged by cursor, lastID is id of the last returned
+// record, and gets only link to next page.
+func setPageHeaders(w http.ResponseWriter, r *http.Request, page datalayer.Page, count int, lastID int,
+	total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	var links []string
+	if _, cursor := r.URL.Query()["after"]; cursor {
+		if count == page.Limit {
+			links = append(links, pageLink(r.URL, "after", lastID, "next"))
+		}
+	} else {
+		if page.Offset+count < total {
+			links = append(links, pageLink(r.URL, "offset", page.Offset+page.Limit, "next"))
+		}
+		if page.Offset > 0 {
+			prevOffset := page.Offset - page.Limit
+			if prevOffset < 0 {
+				prevOffset = 0
+			}
+			links = append(links, pageLink(r.URL, "offset", prevOffset, "prev"))
+		}
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLink creates one link of Link header, url of request with changed page parameter.
+func pageLink(requestURL *url.URL, param string, value int, rel string) string {
+	query := requestURL.Query()
+	query.Set(param, strconv.Itoa(value))
+	return fmt.Sprintf("<%s?%s>; rel=\"%s\"", requestURL.Path, query.Encode(), rel)
+}
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// mergePatchType is media type of JSON Merge Patch (RFC 7396).
+const mergePatchType = "application/merge-patch+json"
+
+// mergePatch applies JSON Merge Patch to decoded json document target and
+// returns patched document: members of patch object replace members of
+// target, null removes them and objects are merged recursively. Patch which
+// is not object replaces the whole target.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// applyMergePatch applies merge patch from request body to json form of
+// original and decodes result to out, name is name of patched entity used
+// in error message.
+func applyMergePatch(r *http.Request, original interface{}, out interface{}, name string) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != mergePatchType && mediaType != "application/json") {
+		return newAPIError(http.StatusUnsupportedMediaType,
+			fmt.Errorf("Content-Type of patch must be %s", mergePatchType))
+	}
+	var patch interface{}
+	if err := decodeBody(r, &patch, "merge patch"); err != nil {
+		return err
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return newAPIError(http.StatusBadRequest, fmt.Errorf("Merge patch of %s must be json object", name))
+	}
+	var target interface{}
+	content, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, &target); err != nil {
+		return err
+	}
+	patched, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patched, out); err != nil {
+		return newAPIError(http.StatusBadRequest, fmt.Errorf("Patched %s is not valid. Error: %s", name, err))
+	}
+	return nil
+}
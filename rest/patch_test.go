@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergePatchShouldFollowRFC7396(t *testing.T) {
+	// examples from appendix A of RFC 7396
+	cases := []struct {
+		target   string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		var target, patch interface{}
+		json.Unmarshal([]byte(c.target), &target)
+		json.Unmarshal([]byte(c.patch), &patch)
+		result, _ := json.Marshal(mergePatch(target, patch))
+		if string(result) != c.expected {
+			t.Errorf("mergePatch[%s, %s] should return %s, but returns: %s", c.target, c.patch, c.expected, result)
+		}
+	}
+}
@@ -1,8 +1,147 @@
 package rest
 
-import "net/http"
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
 
 // RoleHandler type is type for handling requests to role endpoint.
-type RoleHandler struct{}
+type RoleHandler struct {
+	Roles datalayer.RoleStore
+	Links datalayer.LinkStore
+}
+
+// Routes registers routes of role endpoint to router.
+func (h *RoleHandler) Routes(router *Router) {
+	router.HandleFunc("GET", "/role/", h.handleRetrieve)
+	router.HandleAdmin("POST", "/role/", h.handlePost)
+	router.HandleUser("GET", "/role/{id:int}", h.handleGet)
+	router.HandleAdmin("PUT", "/role/{id:int}", h.handlePut)
+	router.HandleAdmin("DELETE", "/role/{id:int}", h.handleDelete)
+}
+
+// handleGet returns role by id with its users and links shared with the role,
+// only links visible for caller are included.
+func (h *RoleHandler) handleGet(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	id := pathInt(r, "id")
+	role, err := h.Roles.Get(id)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Role with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if role.Links != nil {
+		links := h.Links.ForUser(user)
+		visible := []model.RoleLink{}
+		for _, roleLink := range *role.Links {
+			if _, err := links.Get(roleLink.Link.ID); err == sql.ErrNoRows {
+				continue
+			} else if err != nil {
+				return err
+			}
+			visible = append(visible, roleLink)
+		}
+		role.Links = &visible
+	}
+	output, err := json.Marshal(role)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *RoleHandler) handleRetrieve(w http.ResponseWriter, r *http.Request) error {
+	queryParams := r.URL.Query()
+	foundRoles, err := h.Roles.Retrieve(queryParams)
+	if err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if foundRoles == nil {
+		foundRoles = []*model.Role{}
+	}
+	output, err := json.Marshal(foundRoles)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *RoleHandler) handlePost(w http.ResponseWriter, r *http.Request) error {
+	return h.processSave(w, r, 0, 201)
+}
+
+func (h *RoleHandler) handlePut(w http.ResponseWriter, r *http.Request) error {
+	return h.processSave(w, r, pathInt(r, "id"), 200)
+}
+
+func (h *RoleHandler) processSave(w http.ResponseWriter, r *http.Request, id int, status int) error {
+	var role model.Role
+	if err := decodeBody(r, &role, "role"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	role.ID = id
+	role.Name = strings.TrimSpace(role.Name)
+	var v validator
+	v.required("Name", role.Name)
+	v.maxLength("Name", role.Name, maxNameLength)
+	if err := v.err(); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if id > 0 {
+		if _, err := h.Roles.Get(id); err == sql.ErrNoRows {
+			outErr := fmt.Errorf("Role with id=%d was not found. Error: %s", id, err)
+			prepareResponseFromError(w, outErr, 404)
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	outRole, err := h.Roles.Save(role)
+	if err != nil {
+		return err
+	}
+	idmap := make(map[string]int)
+	idmap["id"] = outRole.ID
+	output, err := json.Marshal(idmap)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, status)
+	return nil
+}
 
-func (h *RoleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+func (h *RoleHandler) handleDelete(w http.ResponseWriter, r *http.Request) error {
+	id := pathInt(r, "id")
+	if _, err := h.Roles.Get(id); err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Role with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	role, err := h.Roles.Delete(id, time.Now())
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(role)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
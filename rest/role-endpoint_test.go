@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+// sharedRoles is RoleStore which returns roles with links shared with them.
+type sharedRoles struct {
+	datalayer.RoleStore
+	links []*model.Link
+}
+
+func (r *sharedRoles) Get(id int) (*model.Role, error) {
+	role, err := r.RoleStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	links := []model.RoleLink{}
+	for index, link := range r.links {
+		links = append(links, model.RoleLink{ID: index + 1, Link: link})
+	}
+	role.Links = &links
+	return role, nil
+}
+
+func TestRoleEndpointShouldReturnOnlyVisibleLinks(t *testing.T) {
+	server := newTestServer(t)
+	alice, _ := server.createUser("alice", false)
+	bob, cookie := server.createUser("bob", false)
+	category := server.createCategory("golang", 0)
+	private := server.createLink(alice, "private", category)
+	archived := server.createLink(bob, "archived", category)
+	visible := server.createLink(bob, "visible", category)
+	if _, err := server.store.Links.Delete(archived.ID, time.Now()); err != nil {
+		t.Fatalf("Links.Delete should archive link, but error: %v", err)
+	}
+	role, err := server.store.Roles.Save(model.Role{Name: "editors"})
+	if err != nil {
+		t.Fatalf("Roles.Save should save role, but error: %v", err)
+	}
+	roles := &sharedRoles{RoleStore: server.store.Roles, links: []*model.Link{private, archived, visible}}
+	router := NewRouter()
+	(&RoleHandler{Roles: roles, Links: server.store.Links}).Routes(router.With(func(next http.Handler) http.Handler {
+		return Authenticate(server.store.Sessions, next)
+	}))
+	server.handler = router
+
+	response := server.do("GET", fmt.Sprintf("/role/%d", role.ID), "", cookie)
+	server.expectStatus(response, 200, "GET /role/{id}")
+	var output model.Role
+	server.decode(response, &output)
+	if output.Links == nil || len(*output.Links) != 1 || (*output.Links)[0].Link.ID != visible.ID {
+		t.Errorf("GET /role/{id} should return only link visible for caller, but returns: %v", output.Links)
+	}
+}
+
+func TestUserRoleEndpointShouldValidateAssignment(t *testing.T) {
+	server := newTestServer(t)
+	admin, cookie := server.createUser("admin", true)
+	body := fmt.Sprintf(`{"User": {"ID": %d}, "Role": {"ID": 999}}`, admin.ID)
+	response := server.do("POST", "/user_role/", body, cookie)
+	server.expectStatus(response, 400, "POST /user_role/ with unknown role")
+	var errBody map[string]APIError
+	server.decode(response, &errBody)
+	if errBody["error"].Code != "validation_failed" || errBody["error"].Details["Role"] == "" {
+		t.Errorf("POST /user_role/ with unknown role should return Role detail, but returns: %v", errBody)
+	}
+	response = server.do("POST", "/user_role/", `{}`, cookie)
+	server.expectStatus(response, 400, "POST /user_role/ without user and role")
+	errBody = nil
+	server.decode(response, &errBody)
+	if errBody["error"].Details["User"] == "" || errBody["error"].Details["Role"] == "" {
+		t.Errorf("POST /user_role/ without user and role should return both details, but returns: %v", errBody)
+	}
+}
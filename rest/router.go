@@ -0,0 +1,217 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chytilp/links/model"
+)
+
+// paramsKey is request context key of path parameters of matched route.
+const paramsKey contextKey = "params"
+
+// Router dispatches requests by method and path to handlers of routes.
+// Patterns are paths with parameters in braces, e.g. /link/{id:int}/notes,
+// parameter of type int matches only numbers, parameter without type any
+// segment. Trailing slash is ignored. Path matching some route with other
+// method gets 405 with Allow header, HEAD is served by GET handler without
+// body and OPTIONS returns allowed methods.
+type Router struct {
+	routes     *[]*route
+	middleware func(http.Handler) http.Handler
+}
+
+// route is one pattern with handler of one method.
+type route struct {
+	method   string
+	segments []patternSegment
+	handler  http.Handler
+}
+
+// patternSegment is one part of route pattern, either literal text or
+// named parameter of type.
+type patternSegment struct {
+	literal string
+	param   string
+	typ     string
+}
+
+// NewRouter creates router without routes.
+func NewRouter() *Router {
+	return &Router{routes: &[]*route{}}
+}
+
+// With returns router which shares routes with router and wraps handlers
+// registered through it by middleware, e.g. Authenticate.
+func (rt *Router) With(middleware func(http.Handler) http.Handler) *Router {
+	wrapped := middleware
+	if rt.middleware != nil {
+		outer := rt.middleware
+		wrapped = func(next http.Handler) http.Handler {
+			return outer(middleware(next))
+		}
+	}
+	return &Router{routes: rt.routes, middleware: wrapped}
+}
+
+// Handle registers handler for method and pattern, it panics on pattern
+// with unknown parameter type.
+func (rt *Router) Handle(method string, pattern string, handler http.Handler) {
+	var segments []patternSegment
+	for _, part := range pathSegments(pattern) {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			segments = append(segments, patternSegment{literal: part})
+			continue
+		}
+		name, typ := part[1:len(part)-1], ""
+		if index := strings.Index(name, ":"); index >= 0 {
+			name, typ = name[:index], name[index+1:]
+		}
+		if typ != "" && typ != "int" {
+			panic(fmt.Sprintf("rest: unknown type %s of parameter %s in pattern %s", typ, name, pattern))
+		}
+		segments = append(segments, patternSegment{param: name, typ: typ})
+	}
+	if rt.middleware != nil {
+		handler = rt.middleware(handler)
+	}
+	*rt.routes = append(*rt.routes, &route{method: method, segments: segments, handler: handler})
+}
+
+// HandleFunc registers handler function which returns error for method and
+// pattern, returned error is written by writeError.
+func (rt *Router) HandleFunc(method string, pattern string, handler func(w http.ResponseWriter,
+	r *http.Request) error) {
+	rt.Handle(method, pattern, errorHandler(handler))
+}
+
+// HandleUser registers handler function of logged in user for method and
+// pattern, request without user gets 401.
+func (rt *Router) HandleUser(method string, pattern string, handler func(w http.ResponseWriter,
+	r *http.Request, user *model.User) error) {
+	rt.HandleFunc(method, pattern, func(w http.ResponseWriter, r *http.Request) error {
+		user, ok := requireUser(w, r)
+		if !ok {
+			return nil
+		}
+		return handler(w, r, user)
+	})
+}
+
+// HandleAdmin registers handler function of superadmin for method and
+// pattern, request without user gets 401 and request of other user 403.
+func (rt *Router) HandleAdmin(method string, pattern string, handler func(w http.ResponseWriter,
+	r *http.Request) error) {
+	rt.HandleFunc(method, pattern, func(w http.ResponseWriter, r *http.Request) error {
+		if _, ok := requireSuperadmin(w, r); !ok {
+			return nil
+		}
+		return handler(w, r)
+	})
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := pathSegments(r.URL.Path)
+	allowed := make(map[string]bool)
+	var matched *route
+	var matchedParams map[string]string
+	for _, route := range *rt.routes {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		allowed[route.method] = true
+		if matched == nil && (route.method == r.Method || (route.method == "GET" && r.Method == "HEAD")) {
+			matched, matchedParams = route, params
+		}
+	}
+	if len(allowed) == 0 {
+		notFound(w, r)
+		return
+	}
+	if allowed["GET"] {
+		allowed["HEAD"] = true
+	}
+	allowed["OPTIONS"] = true
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	allow := strings.Join(methods, ", ")
+	switch {
+	case matched != nil:
+		ctx := context.WithValue(r.Context(), paramsKey, matchedParams)
+		if r.Method == "HEAD" && matched.method != "HEAD" {
+			w = &headResponseWriter{ResponseWriter: w}
+		}
+		matched.handler.ServeHTTP(w, r.WithContext(ctx))
+	case r.Method == "OPTIONS":
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", allow)
+		prepareResponseFromError(w, fmt.Errorf("Method %s is not allowed on %s", r.Method, r.URL.Path),
+			http.StatusMethodNotAllowed)
+	}
+}
+
+// match checks that path segments match route pattern and returns values
+// of its parameters.
+func (r *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range r.segments {
+		switch {
+		case segment.param == "":
+			if segments[i] != segment.literal {
+				return nil, false
+			}
+		case segment.typ == "int":
+			if _, err := strconv.Atoi(segments[i]); err != nil {
+				return nil, false
+			}
+			params[segment.param] = segments[i]
+		default:
+			params[segment.param] = segments[i]
+		}
+	}
+	return params, true
+}
+
+// pathParam returns value of path parameter of matched route by name.
+func pathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey).(map[string]string)
+	return params[name]
+}
+
+// pathInt returns value of int path parameter of matched route by name,
+// router matches only numbers to such parameter.
+func pathInt(r *http.Request, name string) int {
+	value, _ := strconv.Atoi(pathParam(r, name))
+	return value
+}
+
+// errorHandler is handler function which returns error, error is written by writeError.
+type errorHandler func(w http.ResponseWriter, r *http.Request) error
+
+func (h errorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h(w, r); err != nil {
+		writeError(w, err)
+	}
+}
+
+// headResponseWriter writes headers of response to HEAD request without body.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(content []byte) (int, error) {
+	return len(content), nil
+}
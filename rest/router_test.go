@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestRouter() *Router {
+	router := NewRouter()
+	router.HandleFunc("GET", "/link/{id:int}/notes/{noteId:int}", func(w http.ResponseWriter, r *http.Request) error {
+		fmt.Fprintf(w, "%d/%d", pathInt(r, "id"), pathInt(r, "noteId"))
+		return nil
+	})
+	router.HandleFunc("DELETE", "/link/{id:int}/notes/{noteId:int}", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(204)
+		return nil
+	})
+	router.HandleFunc("GET", "/category/{name}", func(w http.ResponseWriter, r *http.Request) error {
+		fmt.Fprint(w, pathParam(r, "name"))
+		return nil
+	})
+	return router
+}
+
+func serve(handler http.Handler, method string, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder
+}
+
+func TestRouterShouldMatchTypedPathParams(t *testing.T) {
+	router := newTestRouter()
+	cases := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/link/1/notes/22", 200, "1/22"},
+		{"/link/1/notes/22/", 200, "1/22"},
+		{"/category/golang", 200, "golang"},
+		{"/link/x/notes/22", 404, ""},
+		{"/link/1/notes", 404, ""},
+	}
+	for _, c := range cases {
+		response := serve(router, "GET", c.target)
+		if response.Code != c.status || (c.body != "" && response.Body.String() != c.body) {
+			t.Errorf("GET %s should return %d %s, but returns %d %s", c.target, c.status, c.body,
+				response.Code, response.Body.String())
+		}
+	}
+}
+
+func TestRouterShouldHandleOtherMethods(t *testing.T) {
+	router := newTestRouter()
+	response := serve(router, "POST", "/link/1/notes/2")
+	if response.Code != 405 || response.Header().Get("Allow") != "DELETE, GET, HEAD, OPTIONS" {
+		t.Errorf("POST should return 405 with Allow header, but returns %d, Allow: %s", response.Code,
+			response.Header().Get("Allow"))
+	}
+	response = serve(router, "OPTIONS", "/link/1/notes/2")
+	if response.Code != 204 || response.Header().Get("Allow") != "DELETE, GET, HEAD, OPTIONS" {
+		t.Errorf("OPTIONS should return 204 with Allow header, but returns %d, Allow: %s", response.Code,
+			response.Header().Get("Allow"))
+	}
+	response = serve(router, "HEAD", "/link/1/notes/2")
+	if response.Code != 200 || response.Body.Len() != 0 {
+		t.Errorf("HEAD should return 200 without body, but returns %d: %s", response.Code, response.Body.String())
+	}
+}
+
+func TestRouterShouldRejectUnknownParamType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Router.Handle should panic on unknown parameter type")
+		}
+	}()
+	NewRouter().HandleFunc("GET", "/link/{id:uuid}", func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+}
@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+// SearchHandler type is type for handling requests to search endpoint.
+type SearchHandler struct {
+	Links datalayer.LinkStore
+}
+
+// Routes registers routes of search endpoint to router.
+func (h *SearchHandler) Routes(router *Router) {
+	router.HandleUser("GET", "/search", h.handleSearch)
+}
+
+// handleSearch returns links visible for user matching query q ordered by
+// score, paged by limit and offset.
+func (h *SearchHandler) handleSearch(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	queryParams := r.URL.Query()
+	if _, cursor := queryParams["after"]; cursor {
+		prepareResponseFromError(w, fmt.Errorf("Search can be paged only by limit and offset"), 400)
+		return nil
+	}
+	page, err := parsePage(queryParams)
+	if err != nil {
+		prepareResponseFromError(w, err, 400)
+		return nil
+	}
+	results, total, err := h.Links.ForUser(user).Search(queryParams.Get("q"), page)
+	if errors.Is(err, datalayer.ErrInvalidFilter) {
+		prepareResponseFromError(w, err, 400)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if results == nil {
+		results = []*model.SearchResult{}
+	}
+	setPageHeaders(w, r, page, len(results), 0, total)
+	output, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/chytilp/links/model"
+)
+
+func TestSearchEndpointShouldFindVisibleLinks(t *testing.T) {
+	server := newTestServer(t)
+	owner, cookie := server.createUser("owner", false)
+	other, _ := server.createUser("other", false)
+	category := server.createCategory("languages", 0)
+	server.createLink(owner, "golang", category)
+	server.createLink(other, "golang-other", category)
+
+	response := server.do("GET", "/search?q=GOLANG", "", cookie)
+	server.expectStatus(response, 200, "GET /search?q=GOLANG")
+	var results []model.SearchResult
+	server.decode(response, &results)
+	if len(results) != 1 || results[0].Link.Name != "golang" {
+		t.Fatalf("GET /search should return only link of user, but returns: %v", results)
+	}
+	if total := response.Header().Get("X-Total-Count"); total != "1" {
+		t.Errorf("GET /search should return total count 1, but returns: %s", total)
+	}
+	if len(results[0].Snippets) == 0 || results[0].Snippets[0].Text != "<mark>golang</mark>" {
+		t.Errorf("GET /search should return highlighted snippet, but returns: %v", results[0].Snippets)
+	}
+
+	response = server.do("GET", "/search?q=", "", cookie)
+	server.expectStatus(response, 400, "GET /search?q=")
+	response = server.do("GET", "/search?q=go", "", nil)
+	server.expectStatus(response, 401, "GET /search without session")
+}
@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+// handleGetStars returns rating of link by logged in user on GET /link/{id}/stars.
+func (h *LinkHandler) handleGetStars(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	linkID, ok, err := visibleLink(w, r, h.Links.ForUser(user))
+	if !ok {
+		return err
+	}
+	star, err := h.Stars.Get(user.ID, linkID)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("Link with id=%d is not rated by user. Error: %s", linkID, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return prepareStarResponse(w, star)
+}
+
+// handleSetStars rates link by logged in user on POST and PUT /link/{id}/stars.
+func (h *LinkHandler) handleSetStars(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	linkID, ok, err := visibleLink(w, r, h.Links.ForUser(user))
+	if !ok {
+		return err
+	}
+	var rating model.Star
+	if err := decodeBody(r, &rating, "rating"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if err := validateStars(rating); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	star, err := h.Stars.Set(user.ID, linkID, rating.Stars)
+	if err != nil {
+		return err
+	}
+	return prepareStarResponse(w, star)
+}
+
+// validateStars checks that rating is in allowed range.
+func validateStars(rating model.Star) error {
+	var v validator
+	v.between("Stars", rating.Stars, datalayer.MinStars, datalayer.MaxStars)
+	return v.err()
+}
+
+// handleDeleteStars removes rating of link by logged in user on DELETE /link/{id}/stars.
+func (h *LinkHandler) handleDeleteStars(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	linkID, ok, err := visibleLink(w, r, h.Links.ForUser(user))
+	if !ok {
+		return err
+	}
+	if err := h.Stars.Delete(user.ID, linkID); err != nil {
+		return err
+	}
+	w.WriteHeader(204)
+	return nil
+}
+
+func prepareStarResponse(w http.ResponseWriter, star *model.Star) error {
+	output, err := json.Marshal(star)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
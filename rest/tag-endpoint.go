@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+// TagHandler type is type for handling requests to tag endpoint.
+type TagHandler struct {
+	Tags datalayer.TagStore
+}
+
+// tagMerge type is request body of tag merge, Target is id of tag which
+// gets links of merged tag.
+type tagMerge struct {
+	Target int
+}
+
+// Routes registers routes of tag endpoint to router.
+func (h *TagHandler) Routes(router *Router) {
+	router.HandleUser("GET", "/tag/", h.handleRetrieve)
+	router.HandleUser("GET", "/tag/{id:int}", h.handleGet)
+	router.HandleAdmin("PUT", "/tag/{id:int}", h.handleRename)
+	router.HandleAdmin("POST", "/tag/{id:int}/merge", h.handleMerge)
+}
+
+// handleRetrieve returns all tags with numbers of their links visible for user.
+func (h *TagHandler) handleRetrieve(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	tags, err := h.Tags.ForUser(user).Retrieve()
+	if err != nil {
+		return err
+	}
+	return prepareTagResponse(w, tags)
+}
+
+// handleGet returns one tag on GET /tag/{id}.
+func (h *TagHandler) handleGet(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	tag, err := h.Tags.ForUser(user).Get(pathInt(r, "id"))
+	return writeTag(w, tag, err)
+}
+
+// handleRename changes name of tag on PUT /tag/{id}, tags are shared by all
+// users, so only superadmin can change them.
+func (h *TagHandler) handleRename(w http.ResponseWriter, r *http.Request) error {
+	var renamed model.Tag
+	if err := decodeBody(r, &renamed, "tag"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	tag, err := h.Tags.Rename(pathInt(r, "id"), renamed.Name)
+	return writeTag(w, tag, err)
+}
+
+// handleMerge merges tag to target tag on POST /tag/{id}/merge.
+func (h *TagHandler) handleMerge(w http.ResponseWriter, r *http.Request) error {
+	var merge tagMerge
+	if err := decodeBody(r, &merge, "tag merge"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	tag, err := h.Tags.Merge(pathInt(r, "id"), merge.Target)
+	return writeTag(w, tag, err)
+}
+
+// writeTag writes tag returned by tag store or error of the store.
+func writeTag(w http.ResponseWriter, tag *model.Tag, err error) error {
+	if err == sql.ErrNoRows {
+		prepareResponseFromError(w, fmt.Errorf("Tag was not found. Error: %s", err), 404)
+		return nil
+	}
+	if err != nil {
+		// invalid and duplicate names are mapped by writeError
+		return err
+	}
+	return prepareTagResponse(w, tag)
+}
+
+func prepareTagResponse(w http.ResponseWriter, content interface{}) error {
+	output, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chytilp/links/model"
+)
+
+func TestTagEndpointShouldListRenameAndMergeTags(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("owner", false)
+	_, adminCookie := server.createUser("admin", true)
+	category := server.createCategory("golang", 0)
+	for i, tags := range []string{`["go", "db"]`, `["golang"]`} {
+		body := fmt.Sprintf(`{"Link": "https://go.dev/%d", "Name": "Go", "Category": {"ID": %d}, "Tags": %s}`,
+			i, category.ID, tags)
+		response := server.do("POST", "/link/", body, cookie)
+		server.expectStatus(response, 201, "POST /link/ with tags")
+	}
+	response := server.do("GET", "/tag/", "", cookie)
+	server.expectStatus(response, 200, "GET /tag/")
+	var tags []model.Tag
+	server.decode(response, &tags)
+	if len(tags) != 3 {
+		t.Fatalf("GET /tag/ should return 3 tags, but returns: %v", tags)
+	}
+	ids := make(map[string]int)
+	for _, tag := range tags {
+		ids[tag.Name] = tag.ID
+	}
+
+	response = server.do("PUT", fmt.Sprintf("/tag/%d", ids["golang"]), `{"Name": "golang2"}`, cookie)
+	server.expectStatus(response, 403, "PUT /tag/{id} by user")
+	response = server.do("PUT", fmt.Sprintf("/tag/%d", ids["golang"]), `{"Name": "go"}`, adminCookie)
+	server.expectStatus(response, 409, "PUT /tag/{id} with name of another tag")
+	response = server.do("PUT", fmt.Sprintf("/tag/%d", ids["db"]), `{"Name": ""}`, adminCookie)
+	server.expectStatus(response, 400, "PUT /tag/{id} with empty name")
+
+	body := fmt.Sprintf(`{"Target": %d}`, ids["go"])
+	response = server.do("POST", fmt.Sprintf("/tag/%d/merge", ids["golang"]), body, cookie)
+	server.expectStatus(response, 403, "POST /tag/{id}/merge by user")
+	response = server.do("POST", fmt.Sprintf("/tag/%d/merge", ids["golang"]), body, adminCookie)
+	server.expectStatus(response, 200, "POST /tag/{id}/merge")
+	var merged model.Tag
+	server.decode(response, &merged)
+	if merged.Name != "go" || merged.LinkCount != 2 {
+		t.Errorf("POST /tag/{id}/merge should return target tag with 2 links, but returns: %v", merged)
+	}
+	response = server.do("GET", fmt.Sprintf("/tag/%d", ids["golang"]), "", cookie)
+	server.expectStatus(response, 404, "GET /tag/{id} of merged tag")
+
+	response = server.do("GET", "/link/?tag=go&tag=db&tag_match=all", "", cookie)
+	server.expectStatus(response, 200, "GET /link/?tag=go&tag=db&tag_match=all")
+	if total := response.Header().Get("X-Total-Count"); total != "1" {
+		t.Errorf("GET /link/?tag=go&tag=db&tag_match=all should return 1 link, but returns: %s", total)
+	}
+}
+
+func TestTagEndpointShouldCountOnlyVisibleActiveLinks(t *testing.T) {
+	server := newTestServer(t)
+	owner, cookie := server.createUser("owner", false)
+	_, otherCookie := server.createUser("other", false)
+	category := server.createCategory("golang", 0)
+	for _, name := range []string{"go", "archived"} {
+		if _, err := server.store.Links.ForUser(owner).Save(model.Link{Link: "https://" + name + ".org",
+			Name: name, Category: category, Tags: []string{"go"}}); err != nil {
+			t.Fatalf("Links.Save should save link %s, but error: %v", name, err)
+		}
+	}
+	response := server.do("GET", "/tag/", "", cookie)
+	var tags []model.Tag
+	server.decode(response, &tags)
+	archived, err := server.store.Links.Retrieve(map[string][]string{"l_name": {"archived"}})
+	if err != nil || len(archived) != 1 {
+		t.Fatalf("Links.Retrieve should find archived link, but returns: %v, %v", archived, err)
+	}
+	if _, err := server.store.Links.Delete(archived[0].ID, time.Now()); err != nil {
+		t.Fatalf("Links.Delete should archive link, but error: %v", err)
+	}
+
+	response = server.do("GET", fmt.Sprintf("/tag/%d", tags[0].ID), "", cookie)
+	server.expectStatus(response, 200, "GET /tag/{id}")
+	var tag model.Tag
+	server.decode(response, &tag)
+	if tag.LinkCount != 1 {
+		t.Errorf("GET /tag/{id} should count only active links, but returns: %v", tag)
+	}
+	response = server.do("GET", "/tag/", "", otherCookie)
+	server.expectStatus(response, 200, "GET /tag/ by other user")
+	server.decode(response, &tags)
+	if len(tags) != 1 || tags[0].LinkCount != 0 {
+		t.Errorf("GET /tag/ should not count links of other users, but returns: %v", tags)
+	}
+}
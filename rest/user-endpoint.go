@@ -1,8 +1,209 @@
 package rest
 
-import "net/http"
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
 
 // UserHandler type is type for handling requests to user endpoint.
-type UserHandler struct{}
+type UserHandler struct {
+	Users datalayer.UserStore
+	Roles datalayer.RoleStore
+}
+
+// userPayload is user sent in request body, unlike model.User it accepts password.
+type userPayload struct {
+	model.User
+	Password string
+}
+
+// Routes registers routes of user endpoint to router.
+func (h *UserHandler) Routes(router *Router) {
+	router.HandleFunc("GET", "/user/", h.handleRetrieve)
+	router.HandleAdmin("POST", "/user/", h.handlePost)
+	router.HandleFunc("GET", "/user/{id:int}", h.handleGet)
+	router.HandleUser("PUT", "/user/{id:int}", h.handlePut)
+	router.HandleAdmin("DELETE", "/user/{id:int}", h.handleDelete)
+}
+
+func (h *UserHandler) handleGet(w http.ResponseWriter, r *http.Request) error {
+	id := pathInt(r, "id")
+	user, err := h.Users.Get(id)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("User with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *UserHandler) handleRetrieve(w http.ResponseWriter, r *http.Request) error {
+	queryParams := r.URL.Query()
+	foundUsers, err := h.Users.Retrieve(queryParams)
+	if err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if foundUsers == nil {
+		foundUsers = []*model.User{}
+	}
+	output, err := json.Marshal(foundUsers)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
+
+func (h *UserHandler) handlePost(w http.ResponseWriter, r *http.Request) error {
+	return h.processSave(w, r, 0, 201, nil)
+}
+
+// handlePut changes user by id. Superadmin can change any user, other users
+// only their own name, email and password.
+func (h *UserHandler) handlePut(w http.ResponseWriter, r *http.Request, caller *model.User) error {
+	id := pathInt(r, "id")
+	if caller.Superadmin {
+		return h.processSave(w, r, id, 200, nil)
+	}
+	if id != caller.ID {
+		prepareResponseFromError(w, fmt.Errorf("User with id=%d can be changed only by superadmin", id), 403)
+		return nil
+	}
+	return h.processSave(w, r, id, 200, caller)
+}
+
+// processSave saves user from request body. When self is not nil, the user
+// changes itself and can not change its superadmin flag and roles.
+func (h *UserHandler) processSave(w http.ResponseWriter, r *http.Request, id int, status int,
+	self *model.User) error {
+	var payload userPayload
+	if err := decodeBody(r, &payload, "user"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	user := payload.User
+	user.ID = id
+	user.Password = payload.Password
+	user.Name = strings.TrimSpace(user.Name)
+	user.Email = strings.TrimSpace(user.Email)
+	if err := validateUser(user, h.Roles); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if id > 0 {
+		current, err := h.Users.Get(id)
+		if err == sql.ErrNoRows {
+			outErr := fmt.Errorf("User with id=%d was not found. Error: %s", id, err)
+			prepareResponseFromError(w, outErr, 404)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if self != nil && (user.Superadmin != current.Superadmin || !sameRoles(user.Roles, current.Roles)) {
+			outErr := fmt.Errorf("Superadmin flag and roles of user can be changed only by superadmin")
+			prepareResponseFromError(w, outErr, 403)
+			return nil
+		}
+	}
+	outUser, err := h.Users.Save(user)
+	if err != nil {
+		writeError(w, err)
+		return nil
+	}
+	idmap := make(map[string]int)
+	idmap["id"] = outUser.ID
+	output, err := json.Marshal(idmap)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, status)
+	return nil
+}
+
+// sameRoles checks that roles sent with user are the current roles of user,
+// roles which are not sent are kept.
+func sameRoles(sent *[]model.UserRole, current *[]model.UserRole) bool {
+	if sent == nil {
+		return true
+	}
+	ids := make(map[int]bool)
+	if current != nil {
+		for _, userRole := range *current {
+			ids[userRole.Role.ID] = true
+		}
+	}
+	if len(*sent) != len(ids) {
+		return false
+	}
+	for _, userRole := range *sent {
+		if userRole.Role == nil || !ids[userRole.Role.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateUser checks required fields and email format of user, roles sent
+// with user must exist.
+func validateUser(user model.User, roles datalayer.RoleStore) error {
+	var v validator
+	v.required("Name", user.Name)
+	v.maxLength("Name", user.Name, maxNameLength)
+	v.required("Email", user.Email)
+	v.maxLength("Email", user.Email, maxNameLength)
+	if v.valid("Email") {
+		address, err := mail.ParseAddress(user.Email)
+		if err != nil || address.Address != user.Email {
+			v.fail("Email", "must be valid email address")
+		}
+	}
+	if user.Roles != nil {
+		for _, userRole := range *user.Roles {
+			if userRole.Role == nil || userRole.Role.ID <= 0 || !v.valid("Roles") {
+				continue
+			}
+			if err := v.role("Roles", userRole.Role.ID, roles); err != nil {
+				return err
+			}
+		}
+	}
+	return v.err()
+}
 
-func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+func (h *UserHandler) handleDelete(w http.ResponseWriter, r *http.Request) error {
+	id := pathInt(r, "id")
+	if _, err := h.Users.Get(id); err == sql.ErrNoRows {
+		outErr := fmt.Errorf("User with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	user, err := h.Users.Delete(id, time.Now())
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
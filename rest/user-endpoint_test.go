@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chytilp/links/model"
+)
+
+func TestUserEndpointShouldRejectDuplicateEmail(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("alice", true)
+	body := `{"Name": "Alice", "Email": "alice@example.com", "Password": "secret"}`
+	response := server.do("POST", "/user/", body, cookie)
+	server.expectStatus(response, 409, "POST /user/")
+	body = `{"Name": "Alice", "Email": "ALICE@example.com", "Password": "secret"}`
+	response = server.do("POST", "/user/", body, cookie)
+	server.expectStatus(response, 409, "POST /user/ with email in other case")
+
+	body = `{"Name": "Bob", "Email": "bob@example.com", "Password": "secret"}`
+	response = server.do("POST", "/user/", body, cookie)
+	server.expectStatus(response, 201, "POST /user/")
+	user, err := server.store.Users.FindByEmail("bob@example.com")
+	if err != nil || user.Name != "Bob" {
+		t.Errorf("POST /user/ should save user, but returns: %v, %v", user, err)
+	}
+}
+
+func TestUserEndpointShouldRejectRoleWithoutID(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("alice", true)
+	body := `{"Name": "Bob", "Email": "bob@example.com", "Password": "secret", "Roles": [{"Role": null}]}`
+	response := server.do("POST", "/user/", body, cookie)
+	server.expectStatus(response, 400, "POST /user/ with role without id")
+}
+
+func TestUserEndpointShouldRejectUnknownRole(t *testing.T) {
+	server := newTestServer(t)
+	_, cookie := server.createUser("alice", true)
+	body := `{"Name": "Bob", "Email": "bob@example.com", "Password": "secret", "Roles": [{"Role": {"ID": 999}}]}`
+	response := server.do("POST", "/user/", body, cookie)
+	server.expectStatus(response, 400, "POST /user/ with unknown role")
+	var errBody map[string]APIError
+	server.decode(response, &errBody)
+	if errBody["error"].Code != "validation_failed" || errBody["error"].Details["Roles"] == "" {
+		t.Errorf("POST /user/ with unknown role should return Roles detail, but returns: %v", errBody)
+	}
+}
+
+func TestUserEndpointShouldLimitChangesToSuperadmin(t *testing.T) {
+	server := newTestServer(t)
+	user, cookie := server.createUser("bob", false)
+	other, _ := server.createUser("carol", false)
+	role, err := server.store.Roles.Save(model.Role{Name: "editors"})
+	if err != nil {
+		t.Fatalf("Roles.Save should save role, but error: %v", err)
+	}
+	body := `{"Name": "Bob", "Email": "bob@example.com", "Superadmin": true}`
+	response := server.do("PUT", fmt.Sprintf("/user/%d", user.ID), body, cookie)
+	server.expectStatus(response, 403, "PUT /user/{self} with superadmin")
+	body = fmt.Sprintf(`{"Name": "Bob", "Email": "bob@example.com", "Roles": [{"Role": {"ID": %d}}]}`, role.ID)
+	response = server.do("PUT", fmt.Sprintf("/user/%d", user.ID), body, cookie)
+	server.expectStatus(response, 403, "PUT /user/{self} with roles")
+	body = `{"Name": "Carol", "Email": "carol@example.com", "Password": "changed"}`
+	response = server.do("PUT", fmt.Sprintf("/user/%d", other.ID), body, cookie)
+	server.expectStatus(response, 403, "PUT /user/{other}")
+	response = server.do("POST", "/user/", `{"Name": "Dan", "Email": "dan@example.com", "Password": "secret"}`,
+		cookie)
+	server.expectStatus(response, 403, "POST /user/")
+	response = server.do("DELETE", fmt.Sprintf("/user/%d", other.ID), "", cookie)
+	server.expectStatus(response, 403, "DELETE /user/{other}")
+	response = server.do("POST", "/role/", `{"Name": "editors"}`, cookie)
+	server.expectStatus(response, 403, "POST /role/")
+	body = fmt.Sprintf(`{"User": {"ID": %d}, "Role": {"ID": 1}}`, user.ID)
+	response = server.do("POST", "/user_role/", body, cookie)
+	server.expectStatus(response, 403, "POST /user_role/")
+
+	body = `{"Name": "Robert", "Email": "robert@example.com", "Password": "changed"}`
+	response = server.do("PUT", fmt.Sprintf("/user/%d", user.ID), body, cookie)
+	server.expectStatus(response, 200, "PUT /user/{self}")
+	changed, err := server.store.Users.Get(user.ID)
+	if err != nil || changed.Name != "Robert" || changed.Superadmin {
+		t.Errorf("PUT /user/{self} should change name only, but returns: %v, %v", changed, err)
+	}
+}
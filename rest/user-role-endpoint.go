@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+// UserRoleHandler type is type for handling requests to user_role endpoint.
+type UserRoleHandler struct {
+	Users     datalayer.UserStore
+	Roles     datalayer.RoleStore
+	UserRoles datalayer.UserRoleStore
+}
+
+// Routes registers routes of user_role endpoint to router.
+func (h *UserRoleHandler) Routes(router *Router) {
+	router.HandleAdmin("POST", "/user_role/", h.handlePost)
+	router.HandleAdmin("DELETE", "/user_role/{id:int}", h.handleDelete)
+}
+
+// handlePost assigns role to user. Assigning role which user already has
+// returns the existing assignment, so the request can be safely repeated.
+func (h *UserRoleHandler) handlePost(w http.ResponseWriter, r *http.Request) error {
+	var userRole model.UserRole
+	if err := decodeBody(r, &userRole, "user role"); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	if err := validateUserRole(userRole, h.Users, h.Roles); err != nil {
+		writeError(w, err)
+		return nil
+	}
+	userRole.ID = 0
+	status := 200
+	outUserRole, err := h.UserRoles.Find(userRole.User.ID, userRole.Role.ID)
+	if err == sql.ErrNoRows {
+		status = 201
+		outUserRole, err = h.UserRoles.Save(userRole)
+	}
+	if err != nil {
+		return err
+	}
+	idmap := make(map[string]int)
+	idmap["id"] = outUserRole.ID
+	output, err := json.Marshal(idmap)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, status)
+	return nil
+}
+
+// validateUserRole checks that user and role of assignment are sent and exist.
+func validateUserRole(userRole model.UserRole, users datalayer.UserStore, roles datalayer.RoleStore) error {
+	var v validator
+	if userRole.User == nil || userRole.User.ID <= 0 {
+		v.fail("User", "is required")
+	} else if _, err := users.Get(userRole.User.ID); err == sql.ErrNoRows {
+		v.fail("User", fmt.Sprintf("user with id=%d does not exist", userRole.User.ID))
+	} else if err != nil {
+		return err
+	}
+	if userRole.Role == nil || userRole.Role.ID <= 0 {
+		v.fail("Role", "is required")
+	} else if err := v.role("Role", userRole.Role.ID, roles); err != nil {
+		return err
+	}
+	return v.err()
+}
+
+func (h *UserRoleHandler) handleDelete(w http.ResponseWriter, r *http.Request) error {
+	id := pathInt(r, "id")
+	userRole, err := h.UserRoles.Get(id)
+	if err == sql.ErrNoRows {
+		outErr := fmt.Errorf("User role with id=%d was not found. Error: %s", id, err)
+		prepareResponseFromError(w, outErr, 404)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = h.UserRoles.Delete(id); err != nil {
+		return err
+	}
+	output, err := json.Marshal(userRole)
+	if err != nil {
+		return err
+	}
+	prepareResponseFromBytes(w, output, 200)
+	return nil
+}
@@ -0,0 +1,183 @@
+package rest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chytilp/links/datalayer"
+	"github.com/chytilp/links/model"
+)
+
+const (
+	// maxBodySize is the largest accepted request body in bytes.
+	maxBodySize = 1 << 20
+	// maxNameLength is the longest name of link, category or role in characters.
+	maxNameLength = 255
+	// maxURLLength is the longest url of link in characters.
+	maxURLLength = 2048
+)
+
+// allowedSchemes are schemes of urls which can be saved as links.
+var allowedSchemes = []string{"http", "https"}
+
+// decodeBody reads json request body to out, name is name of expected
+// payload used in error message. Body larger than maxBodySize fails with 413,
+// body which is not valid json with 400.
+func decodeBody(r *http.Request, out interface{}, name string) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, fmt.Errorf("Request body can not be read. Error: %s", err))
+	}
+	if len(body) > maxBodySize {
+		return newAPIError(http.StatusRequestEntityTooLarge,
+			fmt.Errorf("Request body can have at most %d bytes", maxBodySize))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return newAPIError(http.StatusBadRequest, fmt.Errorf("Request body is not valid %s. Error: %s", name, err))
+	}
+	return nil
+}
+
+// validator collects errors of fields of request payload.
+type validator struct {
+	details map[string]string
+}
+
+// fail records message for field, only the first error of field is kept.
+func (v *validator) fail(field string, message string) {
+	if v.details == nil {
+		v.details = make(map[string]string)
+	}
+	if _, ok := v.details[field]; !ok {
+		v.details[field] = message
+	}
+}
+
+// valid checks that no error of field was recorded yet.
+func (v *validator) valid(field string) bool {
+	_, failed := v.details[field]
+	return !failed
+}
+
+// required checks that value of field is not blank.
+func (v *validator) required(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+	}
+}
+
+// maxLength checks that value of field has at most max characters.
+func (v *validator) maxLength(field string, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.fail(field, fmt.Sprintf("can have at most %d characters", max))
+	}
+}
+
+// between checks that value of field is in range from min to max.
+func (v *validator) between(field string, value int, min int, max int) {
+	if value < min || value > max {
+		v.fail(field, fmt.Sprintf("must be between %d and %d", min, max))
+	}
+}
+
+// url checks that value of field is absolute url with host and one of schemes.
+func (v *validator) url(field string, value string, schemes []string) {
+	parsed, err := url.Parse(value)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		v.fail(field, "must be absolute url")
+		return
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(parsed.Scheme, scheme) {
+			return
+		}
+	}
+	v.fail(field, fmt.Sprintf("must have scheme %s", strings.Join(schemes, " or ")))
+}
+
+// category checks that category by id exists and returns it, nil when it
+// does not exist.
+func (v *validator) category(field string, id int, categories datalayer.CategoryStore) (*model.Category, error) {
+	category, err := categories.Get(id)
+	if err == sql.ErrNoRows {
+		v.fail(field, fmt.Sprintf("category with id=%d does not exist", id))
+		return nil, nil
+	}
+	return category, err
+}
+
+// role checks that role by id exists.
+func (v *validator) role(field string, id int, roles datalayer.RoleStore) error {
+	_, err := roles.Get(id)
+	if err == sql.ErrNoRows {
+		v.fail(field, fmt.Sprintf("role with id=%d does not exist", id))
+		return nil
+	}
+	return err
+}
+
+// err returns 400 APIError with details of all recorded errors, nil when
+// payload is valid.
+func (v *validator) err() error {
+	if len(v.details) == 0 {
+		return nil
+	}
+	apiErr := newAPIError(http.StatusBadRequest, fmt.Errorf("Request body is not valid"))
+	apiErr.Code = "validation_failed"
+	apiErr.Details = v.details
+	return apiErr
+}
+
+// validateLink trims text fields of link and checks them, category of link
+// must exist and be active. When original is not nil, only changed category
+// is checked, so link in archived category can be changed in place.
+func validateLink(link *model.Link, original *model.Link, categories datalayer.CategoryStore) error {
+	var v validator
+	link.Link = strings.TrimSpace(link.Link)
+	link.Name = strings.TrimSpace(link.Name)
+	v.required("Link", link.Link)
+	v.maxLength("Link", link.Link, maxURLLength)
+	if v.valid("Link") {
+		v.url("Link", link.Link, allowedSchemes)
+	}
+	v.required("Name", link.Name)
+	v.maxLength("Name", link.Name, maxNameLength)
+	if link.Category == nil || link.Category.ID <= 0 {
+		v.fail("Category", "is required")
+		return v.err()
+	}
+	if original != nil && original.Category != nil && original.Category.ID == link.Category.ID {
+		return v.err()
+	}
+	category, err := v.category("Category", link.Category.ID, categories)
+	if err != nil {
+		return err
+	}
+	if category != nil && category.Active != nil {
+		v.fail("Category", fmt.Sprintf("category with id=%d is archived", category.ID))
+	}
+	return v.err()
+}
+
+// validateCategory trims name of category and checks it, parent category
+// must exist unless it is 0 (root).
+func validateCategory(category *model.Category, categories datalayer.CategoryStore) error {
+	var v validator
+	category.Name = strings.TrimSpace(category.Name)
+	v.required("Name", category.Name)
+	v.maxLength("Name", category.Name, maxNameLength)
+	if category.ParentID < 0 {
+		v.fail("ParentID", "must not be negative")
+	} else if category.ParentID > 0 {
+		if _, err := v.category("ParentID", category.ParentID, categories); err != nil {
+			return err
+		}
+	}
+	return v.err()
+}